@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Unicode directional isolates
+const (
+	LeftToRightIsolate    = "\u2066"
+	RightToLeftIsolate    = "\u2067"
+	PopDirectionalIsolate = "\u2069"
+)
+
+// rtlScripts are the scripts written from right to left
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko,
+	unicode.Samaritan, unicode.Mandaic, unicode.Adlam,
+}
+
+// TextDirection returns the direction of text from its first strong letter
+// like the Unicode bidi algorithm (rules P2 and P3). Combining marks take the
+// direction of their base letter, so they are skipped. Text without a strong
+// letter is left to right.
+func TextDirection(text string) Status {
+	for _, r := range text {
+		if unicode.In(r, unicode.Mn, unicode.Me) || !unicode.IsLetter(r) {
+			continue
+		}
+		if unicode.IsOneOf(rtlScripts, r) {
+			return RTL
+		}
+		return LTR
+	}
+	return LTR
+}
+
+// stripIsolates removes the isolates for bars which don't read them and show
+// them as boxes
+var stripIsolates = strings.NewReplacer(LeftToRightIsolate, "", RightToLeftIsolate, "", PopDirectionalIsolate, "")
+
+// isolate wraps text in the directional isolate of direction, so mixed-script
+// lines are not reordered with the text around them
+func isolate(text string, direction Status) string {
+	if text == "" {
+		return text
+	}
+	if direction == RTL {
+		return RightToLeftIsolate + text + PopDirectionalIsolate
+	}
+	return LeftToRightIsolate + text + PopDirectionalIsolate
+}
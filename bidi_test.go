@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTextDirection(t *testing.T) {
+	tests := []struct {
+		text string
+		want Status
+	}{
+		{text: "Hello world", want: LTR},
+		{text: "שלום עולם", want: RTL},
+		{text: "مرحبا Hello", want: RTL},
+		{text: "Hello مرحبا", want: LTR},
+		{text: "123 - سلام", want: RTL},
+		{text: "( ... )", want: LTR},
+		{text: "\u064b Hello", want: LTR},
+		{text: "\u0301\u064b שלום", want: RTL},
+		{text: "\u05b0\u20dd", want: LTR},
+		{text: "", want: LTR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := TextDirection(tt.text); got != tt.want {
+				t.Errorf("TextDirection() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWaybarRTL(t *testing.T) {
+	defer func(length int) { MaxTextLength = length }(MaxTextLength)
+	MaxTextLength = 8
+
+	lyrics := []LyricLine{{Timestamp: time.Second, Text: "أحبك يا حبيبي"}}
+	waybar := NewWaybar(lyrics, 0, &PlayerInfo{Position: time.Second})
+
+	want := RightToLeftIsolate + "أحبك..." + PopDirectionalIsolate
+	if waybar.Text != want {
+		t.Errorf("NewWaybar().Text = %q, want %q", waybar.Text, want)
+	}
+	if !slices.Contains(waybar.Class, RTL) {
+		t.Errorf("NewWaybar().Class = %v, want %s", waybar.Class, RTL)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
@@ -28,30 +29,78 @@ func init() {
 	}
 }
 
-func SaveCache(lines []LyricLine, filePath string) error {
+func SaveCache(lines []LyricLine, metadata Metadata, filePath string) error {
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		if _, err := fmt.Fprintf(file, "@meta,%s,%s\n", key, metadata[key]); err != nil {
+			return err
+		}
+	}
+
 	for line := range slices.Values(lines) {
-		_, err := fmt.Fprintf(file, "%d,%s\n", line.Timestamp, line.Text)
+		_, err := fmt.Fprintf(file, "%d,%s\n", line.Timestamp, EnhancedText(line))
 		if err != nil {
 			return err
 		}
+
+		// Attributes of the line above are stored as "@name,value" lines
+		if line.Voice != "" {
+			if _, err := fmt.Fprintf(file, "@voice,%s\n", line.Voice); err != nil {
+				return err
+			}
+		}
+		if bg := line.Background; bg != nil {
+			_, err := fmt.Fprintf(file, "@bg,%d,%s\n", bg.Timestamp, EnhancedText(*bg))
+			if err != nil {
+				return err
+			}
+		}
+		if line.Translation != "" {
+			if _, err := fmt.Fprintf(file, "@tr,%s\n", line.Translation); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-func LoadCache(filePath string) ([]LyricLine, error) {
+// loadCacheAttribute applies a "@name,value" cache line to lyric
+func loadCacheAttribute(lyric *LyricLine, name, value string) error {
+	switch name {
+	case "@voice":
+		lyric.Voice = value
+	case "@tr":
+		lyric.Translation = value
+	case "@bg":
+		parts := strings.SplitN(value, ",", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid background line: %s", value)
+		}
+		ts, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return err
+		}
+		timestamp := time.Duration(ts)
+		text, words := ParseWords(parts[1], timestamp)
+		lyric.Background = &LyricLine{Timestamp: timestamp, Text: text, Words: words}
+	}
+	return nil
+}
+
+func LoadCache(filePath string) ([]LyricLine, Metadata, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
 	var lyrics []LyricLine
+	metadata := make(Metadata)
 	scanner := bufio.NewScanner(file)
 
 	for scanner.Scan() {
@@ -61,25 +110,42 @@ func LoadCache(filePath string) ([]LyricLine, error) {
 			continue // Skip invalid lines
 		}
 
+		if parts[0] == "@meta" {
+			if key, value, ok := strings.Cut(parts[1], ","); ok {
+				metadata[key] = value
+			}
+			continue
+		}
+
+		if strings.HasPrefix(parts[0], "@") {
+			if len(lyrics) == 0 {
+				continue
+			}
+			if err := loadCacheAttribute(&lyrics[len(lyrics)-1], parts[0], parts[1]); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
 		ts, err := strconv.Atoi(parts[0])
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		timestamp := time.Duration(ts)
-		text := strings.TrimSpace(parts[1])
+		text, words := ParseWords(parts[1], timestamp)
 
-		lyric := LyricLine{Timestamp: timestamp, Text: text}
+		lyric := LyricLine{Timestamp: timestamp, Text: text, Words: words}
 		lyrics = append(lyrics, lyric)
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if len(lyrics) == 0 {
-		return nil, fmt.Errorf("Number of line found is zero.")
+		return nil, nil, fmt.Errorf("Number of line found is zero.")
 	}
 
-	return lyrics, nil
+	return lyrics, metadata, nil
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	lyrics, metadata, err := ParseTTML(sampleTTML)
+	if err != nil {
+		t.Fatalf("ParseTTML() failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "lyrics.csv")
+	if err := SaveCache(lyrics, metadata, path); err != nil {
+		t.Fatalf("SaveCache() failed: %v", err)
+	}
+
+	got, gotMeta, err := LoadCache(path)
+	if err != nil {
+		t.Fatalf("LoadCache() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(lyrics, got) {
+		t.Errorf("LoadCache() =\n%+v\nwant\n%+v", got, lyrics)
+	}
+
+	if !reflect.DeepEqual(metadata, gotMeta) {
+		t.Errorf("LoadCache() metadata = %v, want %v", gotMeta, metadata)
+	}
+}
+
+func TestCacheRoundTripAngleBrackets(t *testing.T) {
+	lyrics, _, err := ParseLyrics("[00:01.00]I <3> you\n[00:02.00]<00:02.00>Love <3> <00:02.50>you")
+	if err != nil {
+		t.Fatalf("ParseLyrics() failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "lyrics.csv")
+	if err := SaveCache(lyrics, nil, path); err != nil {
+		t.Fatalf("SaveCache() failed: %v", err)
+	}
+
+	got, _, err := LoadCache(path)
+	if err != nil {
+		t.Fatalf("LoadCache() failed: %v", err)
+	}
+	if !reflect.DeepEqual(lyrics, got) {
+		t.Errorf("LoadCache() =\n%+v\nwant\n%+v", got, lyrics)
+	}
+}
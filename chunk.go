@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ChunkFallback is how long every chunk of the last line is shown
+const ChunkFallback = 3 * time.Second
+
+// splitChunks splits text into chunks which fit in width cells and returns
+// their rune offsets. Text is split after spaces when possible and between
+// grapheme clusters otherwise. Spaces at the end of a chunk are left out. It
+// returns nil when text isn't wider than width.
+func splitChunks(text string, width int) [][2]int {
+	if width <= 0 || StringWidth(text) <= width {
+		return nil
+	}
+
+	runes := []rune(text)
+	// Pieces are words with the spaces before them, cut to fit in width
+	var pieces [][2]int
+	offset, pieceStart, used := 0, 0, 0
+	inSpace := false
+	for _, cluster := range Graphemes(text) {
+		n := utf8.RuneCountInString(cluster)
+		space := strings.TrimSpace(cluster) == ""
+		w := clusterWidth(cluster)
+
+		// A word after spaces or a word too wide for a chunk starts a piece
+		if (inSpace && !space) || (!space && used+w > width) {
+			if offset > pieceStart {
+				pieces = append(pieces, [2]int{pieceStart, offset})
+			}
+			pieceStart, used = offset, 0
+		}
+		inSpace = space
+		used += w
+		offset += n
+	}
+	pieces = append(pieces, [2]int{pieceStart, offset})
+
+	var chunks [][2]int
+	chunk := [2]int{-1, -1}
+	for _, piece := range pieces {
+		if chunk[0] >= 0 && StringWidth(strings.TrimSpace(string(runes[chunk[0]:piece[1]]))) <= width {
+			chunk[1] = piece[1]
+			continue
+		}
+		if chunk[0] >= 0 {
+			chunks = append(chunks, chunk)
+		}
+		chunk = piece
+	}
+	chunks = append(chunks, chunk)
+
+	// Leave out the spaces around chunks
+	for i, c := range chunks {
+		part := string(runes[c[0]:c[1]])
+		c[0] += utf8.RuneCountInString(part) - utf8.RuneCountInString(strings.TrimLeft(part, " \t"))
+		c[1] -= utf8.RuneCountInString(part) - utf8.RuneCountInString(strings.TrimRight(part, " \t"))
+		chunks[i] = c
+	}
+	return chunks
+}
+
+// chunkTimes returns when every chunk starts after the line started. Chunks
+// start with their first word when the line has word timings. Otherwise every
+// chunk gets a slice of duration proportional to its width.
+func chunkTimes(line LyricLine, text string, chunks [][2]int, duration time.Duration) []time.Duration {
+	times := make([]time.Duration, len(chunks))
+
+	if len(line.Words) != 0 && text == line.Text {
+		// Rune offsets of the words in the trimmed text
+		var joined strings.Builder
+		for _, word := range line.Words {
+			joined.WriteString(word.Text)
+		}
+		trimmed := utf8.RuneCountInString(joined.String()) - utf8.RuneCountInString(strings.TrimLeft(joined.String(), " \t"))
+
+		for i, chunk := range chunks[1:] {
+			offset := -trimmed
+			for _, word := range line.Words {
+				if offset > chunk[0] {
+					break
+				}
+				times[i+1] = max(word.Timestamp-line.Timestamp, times[i])
+				offset += utf8.RuneCountInString(word.Text)
+			}
+		}
+		return times
+	}
+
+	if duration <= 0 {
+		duration = time.Duration(len(chunks)) * ChunkFallback
+	}
+	runes := []rune(text)
+	total := StringWidth(text)
+	for i, chunk := range chunks {
+		times[i] = time.Duration(float64(duration) * float64(StringWidth(string(runes[:chunk[0]]))) / float64(total))
+	}
+	return times
+}
+
+// Chunk returns the chunk of text, the text shown for line, elapsed after the
+// line started. duration is the time until the next line. It returns false
+// when text fits in MaxTextLength.
+func Chunk(line LyricLine, text string, elapsed, duration time.Duration) (Window, bool) {
+	chunks := splitChunks(text, MaxTextLength)
+	if len(chunks) == 0 {
+		return Window{}, false
+	}
+	times := chunkTimes(line, text, chunks, duration)
+
+	index := 0
+	for i, start := range times {
+		if elapsed >= start {
+			index = i
+		}
+	}
+
+	window := Window{Index: index, Start: chunks[index][0], End: chunks[index][1]}
+	if index+1 < len(chunks) {
+		window.Next = times[index+1]
+	}
+	return window, true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  [][2]int
+	}{
+		{name: "Fits", text: "short", width: 10, want: nil},
+		{name: "Words", text: "never gonna give you up", width: 11, want: [][2]int{{0, 11}, {12, 23}}},
+		{name: "Word wider than a chunk", text: "a supercalifragilistic b", width: 10, want: [][2]int{{0, 1}, {2, 12}, {12, 22}, {23, 24}}},
+		{name: "Wide text without spaces", text: "君の名は君の名は", width: 6, want: [][2]int{{0, 3}, {3, 6}, {6, 8}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitChunks(tt.text, tt.width); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitChunks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunk(t *testing.T) {
+	defer func(width int) { MaxTextLength = width }(MaxTextLength)
+	MaxTextLength = 11
+
+	untimed := LyricLine{Timestamp: 10 * time.Second, Text: "never gonna give you up"}
+	timed := LyricLine{
+		Timestamp: 10 * time.Second,
+		Text:      "never gonna give you up",
+		Words: []LyricWord{
+			{Timestamp: 10 * time.Second, Text: "never "},
+			{Timestamp: 11 * time.Second, Text: "gonna "},
+			{Timestamp: 12 * time.Second, Text: "give "},
+			{Timestamp: 15 * time.Second, Text: "you "},
+			{Timestamp: 16 * time.Second, Text: "up"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		line     LyricLine
+		elapsed  time.Duration
+		duration time.Duration
+		want     Window
+	}{
+		{
+			// "give you up" starts after 12 of 23 cells
+			name: "Proportional first", line: untimed, elapsed: time.Second, duration: 23 * time.Second,
+			want: Window{Index: 0, Start: 0, End: 11, Next: 12 * time.Second},
+		},
+		{
+			name: "Proportional last", line: untimed, elapsed: 12 * time.Second, duration: 23 * time.Second,
+			want: Window{Index: 1, Start: 12, End: 23},
+		},
+		{
+			name: "Last line", line: untimed, elapsed: 0,
+			want: Window{Index: 0, Start: 0, End: 11, Next: 6 * time.Second * 12 / 23},
+		},
+		{
+			name: "Word timings", line: timed, elapsed: time.Second, duration: 10 * time.Second,
+			want: Window{Index: 0, Start: 0, End: 11, Next: 2 * time.Second},
+		},
+		{
+			name: "Word timings second chunk", line: timed, elapsed: 2 * time.Second, duration: 10 * time.Second,
+			want: Window{Index: 1, Start: 12, End: 23},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Chunk(tt.line, tt.line.Text, tt.elapsed, tt.duration)
+			if !ok {
+				t.Fatal("Chunk() returned no chunk")
+			}
+			if got != tt.want {
+				t.Errorf("Chunk() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/MatusOllah/slogcolor"
 	"github.com/fatih/color"
@@ -17,28 +19,159 @@ var (
 	ToggleState   = false
 	VerboseLog    = false
 	MaxTextLength = 150
+	TruncateWords = false
 	TooltipLines  = 8
 	TootlipColor  = "#cccccc"
+	Karaoke       = false
+	KaraokeColor  = "#1db954"
+	Translation   = "tooltip"
 	LogFilePath   = ""
+
+	RequestInterval = 500 * time.Millisecond
+	PrefetchJobs    = 4
+	DryRun          = false
+	StrictParse     = false
+	ExportFormat    = "lrc"
+
+	TranslationInterval = 4 * time.Second
+
+	MarqueeMode       = false
+	MarqueeSpeed      = 8.0
+	MarqueePauseStart = 1500 * time.Millisecond
+	MarqueePauseEnd   = 1 * time.Second
+	SplitLines        = false
+
+	NoMarkup = false
+	Output   = "waybar"
+
+	ClientMode = false
+	ServeAddr  = ""
+
+	NotifyTrack = false
+	LyricOSD    = false
+
+	OnLine          = ""
+	OnTrack         = ""
+	OnState         = ""
+	OnLyricsMissing = ""
+	HookTimeout     = 5 * time.Second
+
+	TextFormat     = ""
+	TooltipFormat  = ""
+	PausedFormat   = ""
+	NoLyricsFormat = ""
+	WaitingFormat  = ""
+
+	Romanize        []string
+	CyrillicScheme  = "bgn"
+	RomanizeTooltip = false
 )
 
+// subcommand returns the first argument which isn't a flag or the value of a
+// flag
+func subcommand(flags *pflag.FlagSet, args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			return arg
+		case strings.Contains(arg, "="):
+			continue
+		case strings.HasPrefix(arg, "--"):
+			// Flags without NoOptDefVal, which isn't a bool, take the next
+			// argument as value
+			if f := flags.Lookup(arg[2:]); f != nil && f.NoOptDefVal == "" {
+				i++
+			}
+		default:
+			// Shorthands can be combined, e.g. "-vj 4". The value of the
+			// first shorthand which takes one is the rest of the argument or
+			// the next argument.
+			for j, c := range arg[1:] {
+				f := flags.ShorthandLookup(string(c))
+				if f == nil {
+					break
+				}
+				if f.NoOptDefVal == "" {
+					if j == len(arg)-2 {
+						i++
+					}
+					break
+				}
+			}
+		}
+	}
+	return ""
+}
+
 func init() {
 	pflag.BoolVar(&PrintInit, "init", PrintInit, "Show JSON snippet for waybar/config.jsonc")
 	pflag.BoolVar(&PrintVersion, "version", PrintVersion, "Print the version of waybar-lyric")
 	pflag.BoolVar(&ToggleState, "toggle", ToggleState, "Toggle player state (pause/resume)")
-	pflag.IntVar(&MaxTextLength, "max-length", MaxTextLength, "Maximum length of lyrics text")
+	pflag.IntVar(&MaxTextLength, "max-length", MaxTextLength, "Maximum width of lyrics text in cells (CJK characters are two cells wide)")
+	pflag.BoolVar(&TruncateWords, "truncate-words", TruncateWords, "Cut long lyrics at a word boundary")
+	pflag.BoolVar(&MarqueeMode, "marquee", MarqueeMode, "Scroll lyrics longer than --max-length instead of truncating them")
+	pflag.Float64Var(&MarqueeSpeed, "marquee-speed", MarqueeSpeed, "Scroll speed of the marquee in characters per second")
+	pflag.DurationVar(&MarqueePauseStart, "marquee-pause-start", MarqueePauseStart, "Pause before the marquee starts scrolling")
+	pflag.DurationVar(&MarqueePauseEnd, "marquee-pause-end", MarqueePauseEnd, "Pause at the end of the marquee")
+	pflag.BoolVar(&SplitLines, "split", SplitLines, "Show lyrics longer than --max-length in timed chunks instead of truncating them")
 	pflag.IntVar(&TooltipLines, "tooltip-lines", TooltipLines, "Maximum lines of waybar tooltip")
 	pflag.StringVarP(&TootlipColor, "tooltip-color", "t", TootlipColor, "Maximum length of lyrics text")
+	pflag.BoolVar(&Karaoke, "karaoke", Karaoke, "Highlight sung words when lyrics have word timings")
+	pflag.StringVar(&KaraokeColor, "karaoke-color", KaraokeColor, "Color of sung words in karaoke mode")
+	pflag.StringVar(&Translation, "translation", Translation, "Where to show translations (none, tooltip, line, alternate)")
+	pflag.DurationVar(&TranslationInterval, "translation-interval", TranslationInterval, "Time between switching to the translation in alternate mode")
+	pflag.StringSliceVar(&Romanize, "romanize", Romanize, "Scripts to romanize (kana, hangul, cyrillic)")
+	pflag.StringVar(&CyrillicScheme, "cyrillic-scheme", CyrillicScheme, "Romanization of Cyrillic (bgn, iso9, scholarly)")
+	pflag.BoolVar(&RomanizeTooltip, "romanize-tooltip", RomanizeTooltip, "Show the original text of romanized lines in the tooltip")
+	pflag.StringVarP(&Output, "output", "o", Output, "Output format (waybar, i3bar, polybar, yambar, eww, plain)")
+	pflag.BoolVar(&ClientMode, "client", ClientMode, "Stream the output of the daemon and start it when it isn't running")
+	pflag.StringVar(&ServeAddr, "serve", ServeAddr, "Serve lyrics over HTTP on the address, e.g. 127.0.0.1:8080")
+	pflag.BoolVar(&NotifyTrack, "notify", NotifyTrack, "Show a notification when the track changes")
+	pflag.BoolVar(&LyricOSD, "osd", LyricOSD, "Show every lyrics line as a notification")
+	pflag.StringVar(&OnLine, "on-line", OnLine, "Shell command to run when the lyrics line changes")
+	pflag.StringVar(&OnTrack, "on-track", OnTrack, "Shell command to run when the track changes")
+	pflag.StringVar(&OnState, "on-state", OnState, "Shell command to run when the player starts, pauses or stops")
+	pflag.StringVar(&OnLyricsMissing, "on-lyrics-missing", OnLyricsMissing, "Shell command to run when lyrics are not found")
+	pflag.DurationVar(&HookTimeout, "hook-timeout", HookTimeout, "Time after which a hook command is killed")
+	pflag.BoolVar(&NoMarkup, "no-markup", NoMarkup, "Print plain text without Pango markup")
+	pflag.StringVar(&TextFormat, "text-format", TextFormat, "Go template of the lyrics text")
+	pflag.StringVar(&TooltipFormat, "tooltip-format", TooltipFormat, "Go template of the tooltip")
+	pflag.StringVar(&PausedFormat, "paused-format", PausedFormat, "Go template of the text when the player is paused")
+	pflag.StringVar(&NoLyricsFormat, "no-lyrics-format", NoLyricsFormat, "Go template of the text when lyrics are not found")
+	pflag.StringVar(&WaitingFormat, "waiting-format", WaitingFormat, "Go template of the text before the first lyrics line")
 	pflag.BoolVarP(&VerboseLog, "verbose", "v", VerboseLog, "Use verbose logging")
 	pflag.StringVar(&LogFilePath, "log-file", LogFilePath, "File where logs should be saved")
+	pflag.DurationVar(&RequestInterval, "request-interval", RequestInterval, "Minimum delay between LrcLib requests")
+	pflag.IntVarP(&PrefetchJobs, "jobs", "j", PrefetchJobs, "Number of concurrent lookups for prefetch")
+	pflag.BoolVar(&DryRun, "dry-run", DryRun, "Print the publish payload without sending it")
+	pflag.BoolVar(&StrictParse, "strict", StrictParse, "Fail on malformed lines of local lyrics files instead of skipping them")
+	pflag.StringVar(&ExportFormat, "format", ExportFormat, "Format of exported lyrics (lrc, srt, vtt, json)")
 
 	pflag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s prefetch <playlist|csv|directory> [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s publish [file.lrc|artist - title] [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s lint <file>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s export [file|artist - title] [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s offset [global|player|track] [value]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s daemon [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s tui [options]\n", os.Args[0])
 		fmt.Fprint(os.Stderr, "Get spotify lyrics on waybar.\n\n")
 		fmt.Println("Options:")
 		fmt.Println(pflag.CommandLine.FlagUsages())
 	}
 
+	// Negative offsets like "-100ms" are arguments of the offset command, so
+	// flags must come before it
+	if subcommand(pflag.CommandLine, os.Args[1:]) == "offset" {
+		pflag.CommandLine.SetInterspersed(false)
+	}
 	pflag.Parse()
 
 	opts := slogcolor.DefaultOptions
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestSubcommand(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.BoolP("verbose", "v", false, "")
+	flags.IntP("jobs", "j", 1, "")
+	flags.String("log-file", "", "")
+
+	tests := []struct {
+		args string
+		want string
+	}{
+		{args: "offset -100ms", want: "offset"},
+		{args: "--log-file /tmp/log offset -100ms", want: "offset"},
+		{args: "--log-file=/tmp/log offset", want: "offset"},
+		{args: "-v offset -100ms", want: "offset"},
+		{args: "--verbose tui", want: "tui"},
+		{args: "-j 4 prefetch tracks.csv", want: "prefetch"},
+		{args: "-vj 4 prefetch", want: "prefetch"},
+		{args: "-j4 prefetch", want: "prefetch"},
+		{args: "-- offset", want: "offset"},
+		{args: "--verbose", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := subcommand(flags, strings.Fields(tt.args)); got != tt.want {
+			t.Errorf("subcommand(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Nadim147c/go-mpris"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// daemonStartTimeout is how long a client waits for a daemon it started
+	daemonStartTimeout = 3 * time.Second
+	// clientRetry is the delay before a client reconnects to the daemon
+	clientRetry = 5 * time.Second
+	// clientWriteTimeout is how long the daemon waits for a slow client
+	clientWriteTimeout = time.Second
+)
+
+// SocketPath returns the path of the daemon socket in $XDG_RUNTIME_DIR
+func SocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("waybar-lyric-%d.sock", os.Getuid()))
+	}
+	return filepath.Join(dir, "waybar-lyric.sock")
+}
+
+// Broadcast is an io.Writer which sends every write to all clients of the
+// daemon. Every write must be a whole update. New clients get the last update
+// when they connect.
+type Broadcast struct {
+	mu      sync.Mutex
+	clients []net.Conn
+	last    []byte
+}
+
+// send writes p to conn and reports whether the client is still connected
+func (b *Broadcast) send(conn net.Conn, p []byte) bool {
+	conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+	if _, err := conn.Write(p); err != nil {
+		slog.Debug("Client disconnected", "error", err)
+		conn.Close()
+		return false
+	}
+	return true
+}
+
+// Write sends p to all clients. Clients which fail are disconnected.
+func (b *Broadcast) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.last = slices.Clone(p)
+	b.clients = slices.DeleteFunc(b.clients, func(conn net.Conn) bool {
+		return !b.send(conn, p)
+	})
+	return len(p), nil
+}
+
+// Add adds a client and sends it the last update
+func (b *Broadcast) Add(conn net.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last != nil && !b.send(conn, b.last) {
+		return
+	}
+	b.clients = append(b.clients, conn)
+}
+
+// Serve adds the clients accepted by l until l is closed
+func (b *Broadcast) Serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Error("Failed to accept client", "error", err)
+			}
+			return
+		}
+		slog.Debug("Client connected")
+		b.Add(conn)
+	}
+}
+
+// lockDaemon takes an exclusive lock next to the daemon socket, so only one of
+// the daemons which clients spawn at the same time replaces the socket. The
+// lock is held until the returned file is closed.
+func lockDaemon(path string) (*os.File, error) {
+	lock, err := lockFile(path+".lock", syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return nil, fmt.Errorf("daemon is already running on %s", path)
+	}
+	return lock, err
+}
+
+// lockFile opens path and locks it with flock(2) operation how. The lock is
+// held until the returned file is closed.
+func lockFile(path string, how int) (*os.File, error) {
+	lock, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), how); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return lock, nil
+}
+
+// StartDaemon listens on the daemon socket and sends the output to its
+// clients instead of stdout. The returned function removes the socket.
+func StartDaemon() (func(), error) {
+	path := SocketPath()
+	lock, err := lockDaemon(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		lock.Close()
+		return nil, fmt.Errorf("daemon is already running on %s", path)
+	}
+	// A socket nobody listens on is left over from a daemon which crashed
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	slog.Info("Daemon listening", "socket", path)
+
+	broadcast := &Broadcast{}
+	go broadcast.Serve(l)
+
+	// Clients render the output with their own sink, so the daemon always
+	// sends waybar JSON with markup
+	OutputSink = &WaybarSink{}
+	OutputWriter = broadcast
+	NoMarkup = false
+
+	return func() {
+		l.Close()
+		lock.Close()
+	}, nil
+}
+
+// waitForPlayer waits until a player is found
+func waitForPlayer(conn *dbus.Conn) *mpris.Player {
+	slog.Info("Waiting for a player")
+	for {
+		time.Sleep(SleepTime)
+		if player, err := FindPlayer(conn); err == nil {
+			return player
+		}
+	}
+}
+
+// dialDaemon connects to the daemon and starts it when it isn't running
+func dialDaemon() (net.Conn, error) {
+	path := SocketPath()
+	if conn, err := net.Dial("unix", path); err == nil {
+		return conn, nil
+	}
+
+	if err := spawnDaemon(); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(daemonStartTimeout)
+	for {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("daemon didn't start: %w", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// spawnDaemon starts a daemon in its own session with the options of this
+// process
+func spawnDaemon() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	args := []string{"daemon"}
+	for _, arg := range os.Args[1:] {
+		if arg != "--client" {
+			args = append(args, arg)
+		}
+	}
+
+	slog.Info("Starting daemon", "args", args)
+	cmd := exec.Command(exe, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon: %w", err)
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// RunClient streams the output of the daemon to stdout with OutputSink. It
+// reconnects when the daemon stops.
+func RunClient() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	for {
+		conn, err := dialDaemon()
+		if err != nil {
+			return err
+		}
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+
+		scanner := bufio.NewScanner(conn)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var waybar Waybar
+			if err := json.Unmarshal(scanner.Bytes(), &waybar); err != nil {
+				slog.Error("Failed to decode daemon output", "error", err)
+				continue
+			}
+			if waybar.Alt == "" && waybar.Class == nil {
+				ClearOutput()
+				continue
+			}
+			if NoMarkup {
+				waybar.Text = stripMarkup(waybar.Text)
+				waybar.Tooltip = stripMarkup(waybar.Tooltip)
+			}
+			waybar.Encode()
+		}
+		stopClose()
+		conn.Close()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		slog.Warn("Disconnected from daemon", "error", scanner.Err())
+		ClearOutput()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(clientRetry):
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "test.sock"))
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer l.Close()
+
+	broadcast := &Broadcast{}
+	go broadcast.Serve(l)
+
+	connect := func() *bufio.Reader {
+		conn, err := net.Dial("unix", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return bufio.NewReader(conn)
+	}
+	readLine := func(r *bufio.Reader) string {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("ReadString() error = %v", err)
+		}
+		return line
+	}
+
+	first := connect()
+	// Wait until the first client is added
+	for {
+		broadcast.mu.Lock()
+		n := len(broadcast.clients)
+		broadcast.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	broadcast.Write([]byte("one\n"))
+	if got := readLine(first); got != "one\n" {
+		t.Errorf("first client got %q, want %q", got, "one\n")
+	}
+
+	// A new client gets the last update when it connects
+	second := connect()
+	if got := readLine(second); got != "one\n" {
+		t.Errorf("second client got %q, want %q", got, "one\n")
+	}
+
+	broadcast.Write([]byte("two\n"))
+	for _, r := range []*bufio.Reader{first, second} {
+		if got := readLine(r); got != "two\n" {
+			t.Errorf("client got %q, want %q", got, "two\n")
+		}
+	}
+}
+
+func TestLockDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+
+	lock, err := lockDaemon(path)
+	if err != nil {
+		t.Fatalf("lockDaemon() error = %v", err)
+	}
+	if second, err := lockDaemon(path); err == nil {
+		second.Close()
+		t.Fatal("lockDaemon() succeeded while the lock is held")
+	}
+
+	lock.Close()
+	lock, err = lockDaemon(path)
+	if err != nil {
+		t.Fatalf("lockDaemon() error = %v after the lock was released", err)
+	}
+	lock.Close()
+}
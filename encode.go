@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FormatTimestamp formats a duration as a LRC timestamp (MM:SS.ss)
+func FormatTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	cs := d.Round(10*time.Millisecond) / (10 * time.Millisecond)
+	return fmt.Sprintf("%02d:%02d.%02d", cs/6000, (cs/100)%60, cs%100)
+}
+
+// formatCueTimestamp formats a duration as a subtitle timestamp (HH:MM:SS.mmm)
+// using sep as the decimal separator
+func formatCueTimestamp(d time.Duration, sep string) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Round(time.Millisecond) / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, sep, ms%1000)
+}
+
+// EnhancedText returns the text of line with enhanced LRC word timestamps
+func EnhancedText(line LyricLine) string {
+	if len(line.Words) == 0 {
+		return line.Text
+	}
+
+	var text strings.Builder
+	for _, word := range line.Words {
+		fmt.Fprintf(&text, "<%s>%s", FormatTimestamp(word.Timestamp), word.Text)
+	}
+	return strings.TrimSpace(text.String())
+}
+
+// metadataKeys returns the keys of metadata in the order of KnownTags followed
+// by the unknown keys. The offset is skipped because it is already applied to
+// the lyrics.
+func metadataKeys(metadata Metadata) []string {
+	var keys []string
+	for _, key := range KnownTags {
+		if _, ok := metadata[key]; ok && key != "offset" {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		if !slices.Contains(KnownTags, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// EncodeLRC writes lyrics in the enhanced LRC format with metadata as header
+// tags. Voices are written as "v1:" prefixes, background vocals as a "[bg:]"
+// line and translations as a line with the same timestamp after their line.
+func EncodeLRC(w io.Writer, lyrics Lyrics, metadata Metadata) error {
+	for _, key := range metadataKeys(metadata) {
+		if _, err := fmt.Fprintf(w, "[%s:%s]\n", key, metadata[key]); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range lyrics {
+		text := EnhancedText(line)
+		if line.Voice != "" {
+			text = line.Voice + ": " + text
+		}
+		if _, err := fmt.Fprintf(w, "[%s]%s\n", FormatTimestamp(line.Timestamp), text); err != nil {
+			return err
+		}
+
+		if bg := line.Background; bg != nil {
+			if _, err := fmt.Fprintf(w, "[bg:%s]\n", EnhancedText(*bg)); err != nil {
+				return err
+			}
+		}
+
+		if line.Translation != "" {
+			if _, err := fmt.Fprintf(w, "[%s]%s\n", FormatTimestamp(line.Timestamp), line.Translation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EncodeSimpleLRC writes lyrics as LRC with only the line timestamps and the
+// text, which every LRC reader understands. Header tags, voices, background
+// vocals, word timestamps and translations are left out.
+func EncodeSimpleLRC(w io.Writer, lyrics Lyrics) error {
+	for _, line := range lyrics {
+		if _, err := fmt.Fprintf(w, "[%s]%s\n", FormatTimestamp(line.Timestamp), line.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cueEnd returns the end of the cue of line i: the start of the next line or a
+// few seconds after the last line
+func cueEnd(lyrics Lyrics, i int) time.Duration {
+	if i+1 < len(lyrics) {
+		return lyrics[i+1].Timestamp
+	}
+	return lyrics[i].Timestamp + 5*time.Second
+}
+
+// cueEscaper escapes the characters which would be read as cue tags or
+// entities by subtitle parsers
+var cueEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// EncodeSRT writes lyrics as SubRip subtitles. Every line is shown until the
+// next line starts and empty lines are left out. Translations are written as a
+// second cue with the same timing.
+func EncodeSRT(w io.Writer, lyrics Lyrics) error {
+	n := 0
+	for i, line := range lyrics {
+		start := formatCueTimestamp(line.Timestamp, ",")
+		end := formatCueTimestamp(cueEnd(lyrics, i), ",")
+
+		for _, text := range []string{line.Text, line.Translation} {
+			if text == "" {
+				break
+			}
+			n++
+			if _, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", n, start, end, cueEscaper.Replace(text)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// vttText returns the cue text of line with inline word timestamps
+func vttText(line LyricLine) string {
+	if len(line.Words) == 0 {
+		return cueEscaper.Replace(line.Text)
+	}
+
+	var text strings.Builder
+	for i, word := range line.Words {
+		// The first word starts with the cue
+		if i != 0 || word.Timestamp != line.Timestamp {
+			fmt.Fprintf(&text, "<%s>", formatCueTimestamp(word.Timestamp, "."))
+		}
+		text.WriteString(cueEscaper.Replace(word.Text))
+	}
+	return strings.TrimSpace(text.String())
+}
+
+// EncodeVTT writes lyrics as WebVTT subtitles. Word timings are written as
+// inline timestamps, voices as <v> tags, translations as a second cue with the
+// same timing and metadata as a "NOTE metadata" block.
+func EncodeVTT(w io.Writer, lyrics Lyrics, metadata Metadata) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+
+	if keys := metadataKeys(metadata); len(keys) != 0 {
+		var note strings.Builder
+		note.WriteString("NOTE metadata\n")
+		for _, key := range keys {
+			// A NOTE block can't contain "-->" or empty lines
+			value := strings.ReplaceAll(metadata[key], "-->", "->")
+			fmt.Fprintf(&note, "%s: %s\n", key, strings.ReplaceAll(value, "\n", " "))
+		}
+		note.WriteByte('\n')
+		if _, err := io.WriteString(w, note.String()); err != nil {
+			return err
+		}
+	}
+
+	for i, line := range lyrics {
+		if line.Text == "" {
+			continue
+		}
+
+		text := vttText(line)
+		if line.Voice != "" {
+			text = fmt.Sprintf("<v %s>%s", line.Voice, text)
+		}
+
+		start := formatCueTimestamp(line.Timestamp, ".")
+		end := formatCueTimestamp(cueEnd(lyrics, i), ".")
+		if _, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", start, end, text); err != nil {
+			return err
+		}
+
+		if line.Translation != "" {
+			if _, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", start, end, cueEscaper.Replace(line.Translation)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PlainLyrics returns the lyrics text without timestamps
+func PlainLyrics(lyrics Lyrics) string {
+	var plain strings.Builder
+	for _, line := range lyrics {
+		plain.WriteString(line.Text)
+		plain.WriteByte('\n')
+	}
+	return strings.TrimSpace(plain.String())
+}
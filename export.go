@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ExportFormats are the formats supported by EncodeLyrics
+var ExportFormats = []LyricsFormat{FormatLRC, FormatSRT, FormatVTT, FormatJSON}
+
+// EncodeLyrics writes lyrics in the given format
+func EncodeLyrics(w io.Writer, format LyricsFormat, lyrics Lyrics, metadata Metadata) error {
+	switch format {
+	case FormatLRC:
+		return EncodeLRC(w, lyrics, metadata)
+	case FormatSRT:
+		return EncodeSRT(w, lyrics)
+	case FormatVTT:
+		return EncodeVTT(w, lyrics, metadata)
+	case FormatJSON:
+		return EncodeJSON(w, lyrics, metadata)
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// Export prints the lyrics described by query in ExportFormat. An empty query
+// exports the lyrics of the currently playing track.
+func Export(query string) error {
+	format := LyricsFormat(ExportFormat)
+	if !slices.Contains(ExportFormats, format) {
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	var info *PlayerInfo
+	if query == "" {
+		conn, err := dbus.SessionBus()
+		if err != nil {
+			return fmt.Errorf("failed to create dbus connection: %w", err)
+		}
+
+		player, err := FindPlayer(conn)
+		if err != nil {
+			return err
+		}
+
+		info, err = GetSpotifyInfo(player)
+		if err != nil {
+			return fmt.Errorf("failed to get track metadata: %w", err)
+		}
+	}
+
+	lyrics, metadata, err := ResolveLyrics(query, info)
+	if err != nil {
+		return err
+	}
+
+	return EncodeLyrics(os.Stdout, format, lyrics, metadata)
+}
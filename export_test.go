@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleLRC = `[ti:Song & Dance]
+[ar:Artist]
+[length:00:20.00]
+[00:01.00]v1: <00:01.00>Rock <00:01.50>& <00:02.00>Roll <00:03.00>
+[bg:<00:02.00>(oh <00:02.50>yeah)]
+[00:04.00]I <3 you
+[00:04.00]Je t'aime
+[00:06.00]
+[00:08.00]v2: Last line
+`
+
+func TestExportRoundTrip(t *testing.T) {
+	lyrics, metadata, err := ParseLyrics(sampleLRC)
+	if err != nil {
+		t.Fatalf("ParseLyrics() failed: %v", err)
+	}
+
+	for _, format := range ExportFormats {
+		t.Run(string(format), func(t *testing.T) {
+			var first strings.Builder
+			if err := EncodeLyrics(&first, format, lyrics, metadata); err != nil {
+				t.Fatalf("EncodeLyrics() failed: %v", err)
+			}
+
+			if got := DetectLyricsFormat(first.String()); got != format {
+				t.Errorf("DetectLyricsFormat() = %s, want %s", got, format)
+			}
+
+			parsed, parsedMeta, err := ParseAny(first.String())
+			if err != nil {
+				t.Fatalf("ParseAny() failed: %v\n%s", err, first.String())
+			}
+
+			var second strings.Builder
+			if err := EncodeLyrics(&second, format, parsed, parsedMeta); err != nil {
+				t.Fatalf("EncodeLyrics() failed: %v", err)
+			}
+
+			if first.String() != second.String() {
+				t.Errorf("round trip changed the export:\n%s\nwant\n%s", second.String(), first.String())
+			}
+
+			// Formats with word timings keep the lyrics as they are
+			if format != FormatSRT && format != FormatVTT && !reflect.DeepEqual(parsed, []LyricLine(lyrics)) {
+				t.Errorf("ParseAny() =\n%+v\nwant\n%+v", parsed, lyrics)
+			}
+			if format != FormatSRT && !reflect.DeepEqual(parsedMeta, metadata) {
+				t.Errorf("ParseAny() metadata = %v, want %v", parsedMeta, metadata)
+			}
+		})
+	}
+}
+
+func TestEncodeSRT(t *testing.T) {
+	lyrics := Lyrics{
+		{Timestamp: 1500 * time.Millisecond, Text: "Hello"},
+		{Timestamp: 3 * time.Second},
+		{Timestamp: 3661 * time.Second, Text: "a < b"},
+	}
+
+	var got strings.Builder
+	if err := EncodeSRT(&got, lyrics); err != nil {
+		t.Fatalf("EncodeSRT() failed: %v", err)
+	}
+
+	want := "1\n00:00:01,500 --> 00:00:03,000\nHello\n\n" +
+		"2\n01:01:01,000 --> 01:01:06,000\na &lt; b\n\n"
+	if got.String() != want {
+		t.Errorf("EncodeSRT() =\n%q\nwant\n%q", got.String(), want)
+	}
+}
+
+func TestEncodeSimpleLRC(t *testing.T) {
+	lyrics := Lyrics{
+		{
+			Timestamp:   time.Second,
+			Text:        "Hello world",
+			Words:       []LyricWord{{Timestamp: time.Second, Text: "Hello "}, {Timestamp: 1500 * time.Millisecond, Text: "world"}},
+			Voice:       "v1",
+			Background:  &LyricLine{Text: "(world)"},
+			Translation: "Hallo Welt",
+		},
+		{Timestamp: 3 * time.Second},
+	}
+
+	var got strings.Builder
+	if err := EncodeSimpleLRC(&got, lyrics); err != nil {
+		t.Fatalf("EncodeSimpleLRC() failed: %v", err)
+	}
+
+	want := "[00:01.00]Hello world\n[00:03.00]\n"
+	if got.String() != want {
+		t.Errorf("EncodeSimpleLRC() =\n%q\nwant\n%q", got.String(), want)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Sources of the lyrics in State.Provider
+const (
+	// ProviderLrcLib is the name of the LrcLib lyrics provider
+	ProviderLrcLib = "lrclib"
+	// ProviderCache is used for lyrics loaded from the disk cache
+	ProviderCache = "cache"
+)
+
+// Templates of the waybar output. A nil template keeps the default output.
+var (
+	TextTemplate     *template.Template
+	TooltipTemplate  *template.Template
+	PausedTemplate   *template.Template
+	NoLyricsTemplate *template.Template
+	WaitingTemplate  *template.Template
+)
+
+// TemplateData is the data passed to the output templates. Every text is
+// escaped markup, the raw template function returns the original text.
+type TemplateData struct {
+	// Text and Tooltip are the default output
+	Text    string
+	Tooltip string
+
+	// Line is the current line. Prev and Next are nil at the start and the
+	// end of the lyrics.
+	Line  *LyricLine
+	Prev  *LyricLine
+	Next  *LyricLine
+	Index int
+	Count int
+	// Lyrics are all lines of the track
+	Lyrics []LyricLine
+	// Progress is the part of the current line sung so far, from 0 to 1
+	Progress float64
+
+	Artist     string
+	Title      string
+	Album      string
+	Position   time.Duration
+	Length     time.Duration
+	Percentage int
+	Provider   string
+	// State is "playing", "paused" or "stopped"
+	State string
+}
+
+// markupFunc applies f to the text of escaped markup, so it doesn't change
+// or cut the entities
+func markupFunc(f func(string) string) func(string) string {
+	return func(text string) string {
+		return escapeMarkup(f(unescapeMarkup(text)))
+	}
+}
+
+// templateFuncs are the functions available in output templates
+var templateFuncs = template.FuncMap{
+	"truncate": markupFunc(func(text string) string {
+		return Truncate(text, MaxTextLength, TruncateWords)
+	}),
+	"timestamp": FormatTimestamp,
+	"upper":     markupFunc(strings.ToUpper),
+	"lower":     markupFunc(strings.ToLower),
+	"trim":      strings.TrimSpace,
+	"escape":    escapeMarkup,
+	"raw":       unescapeMarkup,
+}
+
+// ParseTemplates parses the templates given with the format flags
+func ParseTemplates() error {
+	templates := []struct {
+		name   string
+		format string
+		dst    **template.Template
+	}{
+		{"text-format", TextFormat, &TextTemplate},
+		{"tooltip-format", TooltipFormat, &TooltipTemplate},
+		{"paused-format", PausedFormat, &PausedTemplate},
+		{"no-lyrics-format", NoLyricsFormat, &NoLyricsTemplate},
+		{"waiting-format", WaitingFormat, &WaitingTemplate},
+	}
+
+	for _, t := range templates {
+		if t.format == "" {
+			continue
+		}
+		parsed, err := template.New(t.name).Funcs(templateFuncs).Parse(t.format)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", t.name, err)
+		}
+		*t.dst = parsed
+	}
+	return nil
+}
+
+// escapeLine returns a copy of line with escaped text
+func escapeLine(line LyricLine) LyricLine {
+	line.Text = escapeMarkup(line.Text)
+	line.Voice = escapeMarkup(line.Voice)
+	line.Translation = escapeMarkup(line.Translation)
+	line.Original = escapeMarkup(line.Original)
+
+	words := make([]LyricWord, len(line.Words))
+	for i, word := range line.Words {
+		words[i] = LyricWord{Timestamp: word.Timestamp, Text: escapeMarkup(word.Text)}
+	}
+	line.Words = words
+
+	if line.Background != nil {
+		bg := escapeLine(*line.Background)
+		line.Background = &bg
+	}
+	return line
+}
+
+// NewTemplateData creates the template data of lyrics[idx]. idx is -1 before
+// the first line and lyrics is nil when the track has no lyrics.
+func NewTemplateData(lyrics []LyricLine, idx int, info *PlayerInfo) TemplateData {
+	if lyrics != nil {
+		escaped := make([]LyricLine, len(lyrics))
+		for i, line := range lyrics {
+			escaped[i] = escapeLine(line)
+		}
+		lyrics = escaped
+	}
+
+	data := TemplateData{
+		Index:      idx,
+		Count:      len(lyrics),
+		Lyrics:     lyrics,
+		Artist:     escapeMarkup(info.Artist),
+		Title:      escapeMarkup(info.Title),
+		Album:      escapeMarkup(info.Album),
+		Position:   info.Position,
+		Length:     info.Length,
+		Percentage: info.Percentage(),
+		Provider:   CurrentState().Provider,
+		State:      strings.ToLower(string(info.Status)),
+	}
+
+	if idx >= 0 && idx < len(lyrics) {
+		data.Line = &lyrics[idx]
+	}
+	if idx > 0 && idx <= len(lyrics) {
+		data.Prev = &lyrics[idx-1]
+	}
+	if idx+1 < len(lyrics) {
+		data.Next = &lyrics[idx+1]
+	}
+
+	if data.Line != nil && data.Next != nil {
+		if span := data.Next.Timestamp - data.Line.Timestamp; span > 0 {
+			data.Progress = min(max(float64(info.Position-data.Line.Timestamp)/float64(span), 0), 1)
+		}
+	}
+
+	return data
+}
+
+// renderTemplate executes t with data and stores the result in dst. dst is
+// kept when t is nil or fails.
+func renderTemplate(dst *string, t *template.Template, data TemplateData) {
+	if t == nil {
+		return
+	}
+
+	var out strings.Builder
+	if err := t.Execute(&out, data); err != nil {
+		slog.Warn("Failed to execute template", "template", t.Name(), "error", err)
+		return
+	}
+	*dst = out.String()
+}
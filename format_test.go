@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewTemplateData(t *testing.T) {
+	lyrics := []LyricLine{
+		{Timestamp: 1 * time.Second, Text: "first"},
+		{Timestamp: 3 * time.Second, Text: "second"},
+		{Timestamp: 7 * time.Second, Text: "third"},
+	}
+	info := &PlayerInfo{Position: 4 * time.Second, Length: 8 * time.Second}
+
+	data := NewTemplateData(lyrics, 1, info)
+	if data.Line.Text != "second" || data.Prev.Text != "first" || data.Next.Text != "third" {
+		t.Errorf("NewTemplateData() lines = %v, %v, %v", data.Prev, data.Line, data.Next)
+	}
+	if data.Progress != 0.25 {
+		t.Errorf("NewTemplateData().Progress = %v, want 0.25", data.Progress)
+	}
+	if data.Percentage != 50 {
+		t.Errorf("NewTemplateData().Percentage = %v, want 50", data.Percentage)
+	}
+
+	data = NewTemplateData(lyrics, -1, info)
+	if data.Line != nil || data.Prev != nil || data.Next.Text != "first" {
+		t.Errorf("NewTemplateData() before the first line = %v, %v, %v", data.Prev, data.Line, data.Next)
+	}
+
+	defer UpdateState(EventState, func(s *State) { *s = State{Index: -1} })
+	UpdateState(EventLyrics, func(s *State) { s.Provider = ProviderCache })
+	if data = NewTemplateData(lyrics, 0, info); data.Provider != ProviderCache {
+		t.Errorf("NewTemplateData().Provider = %q, want %q", data.Provider, ProviderCache)
+	}
+}
+
+func TestTextTemplate(t *testing.T) {
+	lyrics := []LyricLine{
+		{Timestamp: 1 * time.Second, Text: "first"},
+		{Timestamp: 3 * time.Second, Text: "second"},
+	}
+
+	defer func(format string) {
+		TextFormat = format
+		TextTemplate = nil
+	}(TextFormat)
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{name: "Default", format: "", want: "first"},
+		{name: "Line", format: "{{ .Line.Text | upper }}", want: "FIRST"},
+		{name: "Next", format: "{{ .Line.Text }} → {{ with .Next }}{{ .Text }}{{ end }}", want: "first → second"},
+		{name: "Fields", format: "{{ .Index }}/{{ .Count }} {{ .Artist }} {{ timestamp .Position }}", want: "0/2 Artist 00:02.00"},
+		{name: "Fails", format: "{{ .Prev.Text }}", want: "first"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			TextFormat, TextTemplate = tt.format, nil
+			if err := ParseTemplates(); err != nil {
+				t.Fatalf("ParseTemplates() error = %v", err)
+			}
+
+			info := &PlayerInfo{Artist: "Artist", Position: 2 * time.Second}
+			waybar := NewWaybar(lyrics, 0, info)
+			if got := stripIsolates.Replace(waybar.Text); got != tt.want {
+				t.Errorf("NewWaybar().Text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	TextFormat = "{{ .Line.Text"
+	if err := ParseTemplates(); err == nil {
+		t.Error("ParseTemplates() of an invalid template succeeded")
+	}
+}
+
+func TestTemplateEscape(t *testing.T) {
+	lyrics := []LyricLine{{
+		Timestamp: time.Second,
+		Text:      "Rock & <Roll>",
+		Words:     []LyricWord{{Timestamp: time.Second, Text: "Rock & "}, {Timestamp: 2 * time.Second, Text: "<Roll>"}},
+	}}
+
+	defer func(format string) {
+		TextFormat = format
+		TextTemplate = nil
+	}(TextFormat)
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{name: "Line", format: "{{ .Line.Text }}", want: "Rock &amp; &lt;Roll&gt;"},
+		{name: "Words", format: "{{ range .Line.Words }}[{{ .Text }}]{{ end }}", want: "[Rock &amp; ][&lt;Roll&gt;]"},
+		{name: "Track", format: "{{ .Artist }} - {{ .Title }}", want: "Simon &amp; Garfunkel - &lt;untitled&gt;"},
+		{name: "Upper", format: "{{ .Title | upper }}", want: "&lt;UNTITLED&gt;"},
+		{name: "Raw", format: "<b>{{ raw .Artist }}</b>", want: "<b>Simon & Garfunkel</b>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			TextFormat, TextTemplate = tt.format, nil
+			if err := ParseTemplates(); err != nil {
+				t.Fatalf("ParseTemplates() error = %v", err)
+			}
+
+			info := &PlayerInfo{Artist: "Simon & Garfunkel", Title: "<untitled>", Position: 2 * time.Second}
+			waybar := NewWaybar(lyrics, 0, info)
+			if got := stripIsolates.Replace(waybar.Text); got != tt.want {
+				t.Errorf("NewWaybar().Text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The lyrics of the player are kept as is
+	if lyrics[0].Text != "Rock & <Roll>" || lyrics[0].Words[1].Text != "<Roll>" {
+		t.Errorf("NewTemplateData() changed the lyrics: %+v", lyrics[0])
+	}
+}
@@ -8,10 +8,10 @@ require (
 	github.com/fatih/color v1.16.0
 	github.com/godbus/dbus/v5 v5.1.0
 	github.com/spf13/pflag v1.0.6
+	golang.org/x/sys v0.14.0
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.14.0 // indirect
 )
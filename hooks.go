@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// hookQueue is the number of events waiting for a hook. Events are dropped
+// when it is full.
+const hookQueue = 16
+
+// Hook is a shell command which runs on a state event
+type Hook struct {
+	Name    string
+	Command string
+	// match reports whether the hook runs for the event
+	match func(event Event, s State) bool
+	queue chan hookEvent
+}
+
+// hookEvent is an event waiting for a hook
+type hookEvent struct {
+	event Event
+	state State
+}
+
+// NewHooks returns the hooks of the --on-* flags which are set
+func NewHooks() []*Hook {
+	hooks := []*Hook{
+		{Name: "on-line", Command: OnLine, match: func(e Event, s State) bool {
+			return e == EventLine
+		}},
+		{Name: "on-track", Command: OnTrack, match: func(e Event, s State) bool {
+			return e == EventTrack
+		}},
+		{Name: "on-state", Command: OnState, match: func(e Event, s State) bool {
+			return e == EventState
+		}},
+		{Name: "on-lyrics-missing", Command: OnLyricsMissing, match: func(e Event, s State) bool {
+			return e == EventLyrics && s.Lyrics == nil
+		}},
+	}
+
+	var set []*Hook
+	for _, h := range hooks {
+		if h.Command != "" {
+			h.queue = make(chan hookEvent, hookQueue)
+			set = append(set, h)
+		}
+	}
+	return set
+}
+
+// hookEnv returns the environment variables of the event
+func hookEnv(event Event, s State) []string {
+	env := map[string]string{
+		"EVENT":         string(event),
+		"PLAYER":        s.Player,
+		"STATUS":        s.Status,
+		"PROVIDER":      s.Provider,
+		"LYRICS_STATUS": string(s.LyricsStatus),
+		"INDEX":         strconv.Itoa(s.Index),
+		"OFFSET":        strconv.FormatInt(s.Offset.Milliseconds(), 10),
+	}
+	if s.Track != nil {
+		env["TRACK_ID"] = s.Track.ID
+		env["TITLE"] = s.Track.Title
+		env["ARTIST"] = s.Track.Artist
+		env["ALBUM"] = s.Track.Album
+		env["LENGTH"] = strconv.FormatInt(s.Track.Length.Milliseconds(), 10)
+		env["POSITION"] = strconv.FormatInt(s.Track.Position.Milliseconds(), 10)
+	}
+	if line := s.Line(); line != nil {
+		env["LINE"] = line.Text
+		env["TRANSLATION"] = line.Translation
+		env["TIMESTAMP"] = strconv.FormatInt(line.Timestamp.Milliseconds(), 10)
+	}
+	if line := s.Next(); line != nil {
+		env["NEXT_LINE"] = line.Text
+	}
+
+	vars := make([]string, 0, len(env))
+	for key, value := range env {
+		vars = append(vars, "WAYBAR_LYRIC_"+key+"="+value)
+	}
+	return vars
+}
+
+// run runs the command of the hook for e with a timeout of HookTimeout
+func (h *Hook) run(e hookEvent) error {
+	data, err := json.Marshal(newServeState(e.event, e.state, false))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Env = append(os.Environ(), hookEnv(e.event, e.state)...)
+	cmd.Stdin = bytes.NewReader(data)
+	// Kill the children of the shell with it on timeout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", HookTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// worker runs the hook for the queued events in order
+func (h *Hook) worker() {
+	for e := range h.queue {
+		if err := h.run(e); err != nil {
+			slog.Error("Hook failed", "hook", h.Name, "event", e.event, "error", err)
+		}
+	}
+}
+
+// handle queues the event when the hook matches it
+func (h *Hook) handle(event Event, s State) {
+	if !h.match(event, s) {
+		return
+	}
+	select {
+	case h.queue <- hookEvent{event, s}:
+	default:
+		slog.Warn("Dropped event of a slow hook", "hook", h.Name, "event", event)
+	}
+}
+
+// StartHooks runs the hooks of the --on-* flags in the background
+func StartHooks() {
+	for _, h := range NewHooks() {
+		slog.Debug("Starting hook", "hook", h.Name, "command", h.Command)
+		go h.worker()
+		Subscribe(h.handle)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookEnv(t *testing.T) {
+	state := State{
+		Player: "org.mpris.MediaPlayer2.spotify",
+		Status: "playing",
+		Track:  &PlayerInfo{Title: "Title", Artist: "Artist", Position: 1500 * time.Millisecond},
+		Lyrics: Lyrics{{Timestamp: time.Second, Text: "one", Translation: "eins"}, {Timestamp: 2 * time.Second, Text: "two"}},
+		Index:  0,
+	}
+
+	env := hookEnv(EventLine, state)
+	for _, want := range []string{
+		"WAYBAR_LYRIC_EVENT=line",
+		"WAYBAR_LYRIC_STATUS=playing",
+		"WAYBAR_LYRIC_TITLE=Title",
+		"WAYBAR_LYRIC_POSITION=1500",
+		"WAYBAR_LYRIC_LINE=one",
+		"WAYBAR_LYRIC_TRANSLATION=eins",
+		"WAYBAR_LYRIC_NEXT_LINE=two",
+		"WAYBAR_LYRIC_INDEX=0",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("hookEnv() = %v, missing %q", env, want)
+		}
+	}
+}
+
+func TestHookRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	h := &Hook{Name: "on-track", Command: `printf '%s\n' "$WAYBAR_LYRIC_TITLE" > "$OUT"; cat >> "$OUT"`}
+	t.Setenv("OUT", out)
+
+	state := State{Status: "playing", Track: &PlayerInfo{Title: "Title"}, Index: -1}
+	if err := h.run(hookEvent{EventTrack, state}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	title, stdin, _ := strings.Cut(string(data), "\n")
+	if title != "Title" {
+		t.Errorf("title = %q, want %q", title, "Title")
+	}
+	var got serveState
+	if err := json.Unmarshal([]byte(stdin), &got); err != nil {
+		t.Fatalf("stdin is not JSON: %v", err)
+	}
+	if got.Event != EventTrack || got.Track == nil || got.Track.Title != "Title" {
+		t.Errorf("stdin = %+v", got)
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { HookTimeout = timeout }(HookTimeout)
+	HookTimeout = 100 * time.Millisecond
+
+	h := &Hook{Name: "on-line", Command: "sleep 10"}
+	start := time.Now()
+	if err := h.run(hookEvent{EventLine, State{Index: -1}}); err == nil {
+		t.Error("run() of a slow hook succeeded, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("run() took %s, want it killed after %s", elapsed, HookTimeout)
+	}
+}
+
+func TestHookMatch(t *testing.T) {
+	defer func(missing string) { OnLyricsMissing = missing }(OnLyricsMissing)
+	OnLyricsMissing = "true"
+
+	hooks := NewHooks()
+	if len(hooks) != 1 || hooks[0].Name != "on-lyrics-missing" {
+		t.Fatalf("NewHooks() = %v, want only on-lyrics-missing", hooks)
+	}
+	h := hooks[0]
+
+	h.handle(EventLyrics, State{Lyrics: Lyrics{{Text: "one"}}})
+	h.handle(EventLine, State{})
+	if len(h.queue) != 0 {
+		t.Errorf("queued %d events with lyrics, want 0", len(h.queue))
+	}
+	h.handle(EventLyrics, State{LyricsStatus: LyricsMissing})
+	if len(h.queue) != 1 {
+		t.Errorf("queued %d events without lyrics, want 1", len(h.queue))
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// jsonWord is a LyricWord in the JSON lyrics format
+type jsonWord struct {
+	Time int64  `json:"time"`
+	Text string `json:"text"`
+}
+
+// jsonLine is a LyricLine in the JSON lyrics format. Times are milliseconds.
+type jsonLine struct {
+	Time        int64      `json:"time"`
+	Text        string     `json:"text"`
+	Words       []jsonWord `json:"words,omitempty"`
+	Voice       string     `json:"voice,omitempty"`
+	Background  *jsonLine  `json:"background,omitempty"`
+	Translation string     `json:"translation,omitempty"`
+}
+
+// jsonLyrics is the document of the JSON lyrics format
+type jsonLyrics struct {
+	Metadata Metadata   `json:"metadata"`
+	Lines    []jsonLine `json:"lines"`
+}
+
+func toJSONLine(line LyricLine) jsonLine {
+	l := jsonLine{
+		Time:        line.Timestamp.Milliseconds(),
+		Text:        line.Text,
+		Voice:       line.Voice,
+		Translation: line.Translation,
+	}
+	for _, word := range line.Words {
+		l.Words = append(l.Words, jsonWord{Time: word.Timestamp.Milliseconds(), Text: word.Text})
+	}
+	if line.Background != nil {
+		bg := toJSONLine(*line.Background)
+		l.Background = &bg
+	}
+	return l
+}
+
+func fromJSONLine(l jsonLine) LyricLine {
+	line := LyricLine{
+		Timestamp:   time.Duration(l.Time) * time.Millisecond,
+		Text:        l.Text,
+		Voice:       l.Voice,
+		Translation: l.Translation,
+	}
+	for _, word := range l.Words {
+		line.Words = append(line.Words, LyricWord{Timestamp: time.Duration(word.Time) * time.Millisecond, Text: word.Text})
+	}
+	if l.Background != nil {
+		bg := fromJSONLine(*l.Background)
+		line.Background = &bg
+	}
+	return line
+}
+
+// EncodeJSON writes lyrics and metadata as a JSON document
+func EncodeJSON(w io.Writer, lyrics Lyrics, metadata Metadata) error {
+	doc := jsonLyrics{Metadata: metadata, Lines: make([]jsonLine, 0, len(lyrics))}
+	if doc.Metadata == nil {
+		doc.Metadata = Metadata{}
+	}
+	for _, line := range lyrics {
+		doc.Lines = append(doc.Lines, toJSONLine(line))
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	e.SetEscapeHTML(false)
+	return e.Encode(doc)
+}
+
+// ParseJSON parses lyrics written by EncodeJSON
+func ParseJSON(content string) ([]LyricLine, Metadata, error) {
+	var doc jsonLyrics
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse JSON lyrics: %w", err)
+	}
+
+	metadata := doc.Metadata
+	if metadata == nil {
+		metadata = make(Metadata)
+	}
+
+	lyrics := make([]LyricLine, 0, len(doc.Lines))
+	for _, l := range doc.Lines {
+		lyrics = append(lyrics, fromJSONLine(l))
+	}
+
+	if len(lyrics) == 0 {
+		return lyrics, metadata, errors.New("Lyric lines are 0")
+	}
+
+	slices.SortStableFunc(lyrics, func(a, b LyricLine) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+
+	return lyrics, metadata, nil
+}
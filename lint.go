@@ -0,0 +1,253 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KnownTags are the LRC header tags LintLyrics accepts
+var KnownTags = []string{"ar", "al", "ti", "au", "by", "length", "offset", "re", "tool", "ve", "la", "id", "bg", "#"}
+
+// MaxTimestamp is the latest plausible timestamp when the track length is unknown
+const MaxTimestamp = time.Hour
+
+// Diagnostic is a problem found in a lyrics file
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// LintLyrics checks a LRC file and returns every problem found in it
+func LintLyrics(file string) []Diagnostic {
+	var problems []Diagnostic
+	_, _, err := parseLRC(file, func(d Diagnostic) {
+		problems = append(problems, d)
+	})
+
+	if err != nil && len(problems) == 0 {
+		problems = append(problems, Diagnostic{Line: 1, Column: 1, Message: err.Error()})
+	}
+
+	slices.SortStableFunc(problems, func(a, b Diagnostic) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+
+	return problems
+}
+
+type lintStamp struct {
+	line      int
+	column    int
+	timestamp time.Duration
+}
+
+// linter collects diagnostics while parseLRC reads a file. Every method is a
+// no-op when there is nothing to report to.
+type linter struct {
+	send func(Diagnostic)
+
+	lineNo int
+	raw    string
+
+	pending  []lintStamp
+	stamps   []lintStamp
+	previous *lintStamp
+	seen     map[time.Duration]int
+	// translated is the timestamps which already have a translation line
+	translated map[time.Duration]bool
+}
+
+func newLinter(report func(Diagnostic)) *linter {
+	return &linter{send: report, seen: make(map[time.Duration]int), translated: make(map[time.Duration]bool)}
+}
+
+func (l *linter) startLine(n int, raw string) {
+	l.lineNo = n
+	l.raw = raw
+	l.pending = l.pending[:0]
+}
+
+// column returns the column where rest, a suffix of the trimmed line, starts
+func (l *linter) column(rest string) int {
+	end := len(strings.TrimRightFunc(l.raw, unicode.IsSpace))
+	offset := max(end-len(rest), 0)
+	return utf8.RuneCountInString(l.raw[:offset]) + 1
+}
+
+func (l *linter) report(column int, format string, args ...any) {
+	if l.send == nil {
+		return
+	}
+	l.send(Diagnostic{Line: l.lineNo, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) timestamp(column int, tag string, timestamp time.Duration) {
+	if l.send == nil {
+		return
+	}
+
+	parts := strings.Split(tag, ":")
+	if len(parts) >= 2 {
+		if sec, _ := strconv.ParseFloat(strings.TrimSpace(parts[len(parts)-1]), 64); sec >= 60 {
+			l.report(column, "seconds out of range in timestamp %q", tag)
+		}
+	}
+	if len(parts) == 3 {
+		if minutes, _ := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); minutes >= 60 {
+			l.report(column, "minutes out of range in timestamp %q", tag)
+		}
+	}
+
+	l.pending = append(l.pending, lintStamp{line: l.lineNo, column: column, timestamp: timestamp})
+}
+
+func (l *linter) malformedTimestamp(column int, tag string) {
+	if strings.HasPrefix(strings.TrimSpace(tag), "-") {
+		l.report(column, "negative timestamp %q", tag)
+		return
+	}
+	l.report(column, "malformed timestamp %q", tag)
+}
+
+func (l *linter) tag(column int, key, value string) {
+	if l.send == nil {
+		return
+	}
+
+	if !slices.Contains(KnownTags, key) {
+		l.report(column, "unknown tag %q", key)
+		return
+	}
+
+	switch key {
+	case "offset":
+		if _, err := strconv.Atoi(strings.TrimPrefix(value, "+")); err != nil {
+			l.report(column, "invalid offset %q, expected milliseconds", value)
+		}
+	case "length":
+		if _, err := ParseTimestamp(value); err != nil {
+			l.report(column, "invalid length %q", value)
+		}
+	}
+}
+
+// words checks the enhanced LRC word timestamps of a line
+func (l *linter) words(text string) {
+	if l.send == nil {
+		return
+	}
+
+	rest := text
+	for {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 {
+			return
+		}
+		end := strings.IndexByte(rest[start:], '>')
+		if end < 0 {
+			return
+		}
+		tag := rest[start+1 : start+end]
+
+		first := strings.TrimSpace(tag)
+		if first != "" && strings.ContainsRune("0123456789-", rune(first[0])) {
+			if _, err := ParseTimestamp(tag); err != nil || !isTimestamp(tag) {
+				l.report(l.column(rest[start:]), "malformed word timestamp %q", tag)
+			}
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+// line checks the timestamps of a lyrics line against the previous lines
+func (l *linter) line() {
+	if l.send == nil || len(l.pending) == 0 {
+		return
+	}
+
+	first := l.pending[0]
+
+	// A line right after another with the same timestamp is its translation
+	if len(l.pending) == 1 && l.previous != nil && first.timestamp == l.previous.timestamp && !l.translated[first.timestamp] {
+		l.translated[first.timestamp] = true
+		return
+	}
+
+	if l.previous != nil && first.timestamp < l.previous.timestamp {
+		l.report(first.column, "timestamp %s is before %s on line %d",
+			FormatTimestamp(first.timestamp), FormatTimestamp(l.previous.timestamp), l.previous.line)
+	}
+	l.previous = &first
+
+	for _, stamp := range l.pending {
+		if line, ok := l.seen[stamp.timestamp]; ok {
+			l.report(stamp.column, "duplicate timestamp %s, also used on line %d", FormatTimestamp(stamp.timestamp), line)
+		} else {
+			l.seen[stamp.timestamp] = stamp.line
+		}
+	}
+
+	l.stamps = append(l.stamps, l.pending...)
+}
+
+// finish checks timestamps against the length of the track
+func (l *linter) finish(metadata Metadata) {
+	if l.send == nil {
+		return
+	}
+
+	length, err := ParseTimestamp(metadata["length"])
+	known := err == nil && length > 0
+
+	for _, stamp := range l.stamps {
+		l.lineNo = stamp.line
+		switch {
+		case known && stamp.timestamp > length:
+			l.report(stamp.column, "timestamp %s is after the end of the track (%s)",
+				FormatTimestamp(stamp.timestamp), FormatTimestamp(length))
+		case !known && stamp.timestamp > MaxTimestamp:
+			l.report(stamp.column, "implausible timestamp %s", FormatTimestamp(stamp.timestamp))
+		}
+	}
+}
+
+// Lint checks lyrics files and prints every problem. It returns false when a
+// problem is found.
+func Lint(paths []string) bool {
+	ok := true
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			ok = false
+			continue
+		}
+
+		var problems []Diagnostic
+		if format := DetectLyricsFormat(string(content)); format == FormatLRC {
+			problems = LintLyrics(string(content))
+		} else if _, _, err := ParseAny(string(content)); err != nil {
+			problems = append(problems, Diagnostic{Line: 1, Column: 1, Message: err.Error()})
+		}
+
+		for _, problem := range problems {
+			fmt.Printf("%s:%s\n", path, problem)
+		}
+		if len(problems) != 0 {
+			ok = false
+		}
+	}
+	return ok
+}
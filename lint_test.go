@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintLyrics(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want []Diagnostic
+	}{
+		{
+			name: "Valid file",
+			file: "[ar:Queen]\n[offset:+250]\n[00:01.00]First\n[00:02.00]<00:02.00>Second <00:02.50>line\n",
+			want: nil,
+		},
+		{
+			name: "Malformed and negative timestamps",
+			file: "[00:01.00]First\n[00:0x.00]Broken\n  [-00:03.00]Negative\n[00:04.00",
+			want: []Diagnostic{
+				{Line: 2, Column: 1, Message: `malformed timestamp "00:0x.00"`},
+				{Line: 3, Column: 3, Message: `negative timestamp "-00:03.00"`},
+				{Line: 4, Column: 1, Message: "unclosed tag"},
+			},
+		},
+		{
+			name: "Out of order and duplicate timestamps",
+			file: "[00:05.00]First\n[00:03.00]Second\n[00:07.00][00:05.00]Third",
+			want: []Diagnostic{
+				{Line: 2, Column: 1, Message: "timestamp 00:03.00 is before 00:05.00 on line 1"},
+				{Line: 3, Column: 11, Message: "duplicate timestamp 00:05.00, also used on line 1"},
+			},
+		},
+		{
+			name: "Implausible timestamps",
+			file: "[length:03:00]\n[00:75.00]Too many seconds\n[04:00.00]After the end",
+			want: []Diagnostic{
+				{Line: 2, Column: 1, Message: `seconds out of range in timestamp "00:75.00"`},
+				{Line: 3, Column: 1, Message: "timestamp 04:00.00 is after the end of the track (03:00.00)"},
+			},
+		},
+		{
+			name: "Unknown tags and trailing garbage",
+			file: "[xyz:1]\n[ar:Queen] garbage\n[offset:soon]\n[00:01.00]Hello <00:0y.00>world\nno timestamp",
+			want: []Diagnostic{
+				{Line: 1, Column: 1, Message: `unknown tag "xyz"`},
+				{Line: 2, Column: 12, Message: `unexpected text after tag: "garbage"`},
+				{Line: 3, Column: 1, Message: `invalid offset "soon", expected milliseconds`},
+				{Line: 4, Column: 17, Message: `malformed word timestamp "00:0y.00"`},
+				{Line: 5, Column: 1, Message: "line has no timestamp"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LintLyrics(tt.file)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LintLyrics() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLyricsStrict(t *testing.T) {
+	if _, _, err := ParseLyricsStrict("[00:01.00]First\n[00:02.00]Second"); err != nil {
+		t.Errorf("ParseLyricsStrict() failed: %v", err)
+	}
+
+	_, _, err := ParseLyricsStrict("[00:01.00]First\n[00:0x.00]Second")
+	want := Diagnostic{Line: 2, Column: 1, Message: `malformed timestamp "00:0x.00"`}
+	if err != want {
+		t.Errorf("ParseLyricsStrict() error = %v, want %v", err, want)
+	}
+}
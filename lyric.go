@@ -2,21 +2,52 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
-	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
-var LyricStore = make(Store)
+var LyricStore = NewStore()
 
 const LrclibEndpoint = "https://lrclib.net/api/get"
 
+// ErrLyricsNotFound is returned when LrcLib doesn't have lyrics for the track
+var ErrLyricsNotFound = errors.New("Lyrics not found")
+
+// ErrInstrumental is returned when LrcLib marks the track as instrumental
+var ErrInstrumental = fmt.Errorf("%w: track is instrumental", ErrLyricsNotFound)
+
+// ErrUnsynced is returned when LrcLib only has plain lyrics for the track
+var ErrUnsynced = fmt.Errorf("%w: only unsynced lyrics", ErrLyricsNotFound)
+
+var (
+	requestMu   sync.Mutex
+	lastRequest time.Time
+)
+
+// waitRateLimit blocks until at least RequestInterval has passed since the
+// previous LrcLib request. It is shared by every caller of request.
+func waitRateLimit() {
+	requestMu.Lock()
+	defer requestMu.Unlock()
+
+	if wait := RequestInterval - time.Since(lastRequest); wait > 0 {
+		time.Sleep(wait)
+	}
+	lastRequest = time.Now()
+}
+
 func request(params url.Values, header http.Header) (*http.Response, error) {
+	waitRateLimit()
+
 	req, err := http.NewRequest(http.MethodGet, LrclibEndpoint, nil)
 	if err != nil {
 		return nil, err
@@ -32,21 +63,59 @@ func request(params url.Values, header http.Header) (*http.Response, error) {
 	return client.Do(req)
 }
 
+// LyricsKey returns the key used for the memory and file cache of a track. It
+// is made of the artist and the title, so the lyrics of a track are found no
+// matter whether they were fetched for a player, which may send its own track
+// id, or for a playlist.
+func LyricsKey(info *PlayerInfo) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	return StringToMD5(normalize(info.Artist) + "\n" + normalize(info.Title))
+}
+
+// legacyLyricsKey is the cache key of older versions, the base name of the
+// track id
+func legacyLyricsKey(info *PlayerInfo) string {
+	return strings.ReplaceAll(filepath.Base(info.ID), "/", "-")
+}
+
+// lyricsCacheFile returns the disk cache file of info. A file of the legacy
+// key is renamed to it, so lyrics cached or corrected by older versions are
+// kept.
+func lyricsCacheFile(info *PlayerInfo) string {
+	path := filepath.Join(CacheDir, LyricsKey(info)+".csv")
+	if info.ID == "" {
+		return path
+	}
+
+	legacy := filepath.Join(CacheDir, legacyLyricsKey(info)+".csv")
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := os.Rename(legacy, path); err == nil {
+			slog.Info("Moved cached lyrics to the new key", "from", legacy, "to", path)
+		}
+	}
+	return path
+}
+
 func GetLyrics(info *PlayerInfo) (Lyrics, error) {
-	uri := filepath.Base(info.ID)
-	uri = strings.ReplaceAll(uri, "/", "-")
+	uri := LyricsKey(info)
 
 	if val, exists := LyricStore.Load(uri); exists {
 		if len(val) == 0 {
+			if err := LyricStore.LoadError(uri); err != nil {
+				return val, err
+			}
 			return val, fmt.Errorf("Lyrics doesn't exists")
 		}
 		slog.Debug("Lyrics found in memory cache", "lines", len(val))
 		return val, nil
 	}
 
-	cacheFile := filepath.Join(CacheDir, uri+".csv")
+	cacheFile := lyricsCacheFile(info)
 
-	if cachedLyrics, err := LoadCache(cacheFile); err == nil {
+	if cachedLyrics, _, err := LoadCache(cacheFile); err == nil {
+		cachedLyrics = RomanizeLyrics(cachedLyrics)
 		LyricStore.Save(uri, cachedLyrics)
 		return cachedLyrics, nil
 	} else {
@@ -73,42 +142,77 @@ func GetLyrics(info *PlayerInfo) (Lyrics, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		LyricStore.Save(uri, []LyricLine{})
-		return nil, fmt.Errorf("Lyrics not found")
+		return nil, LyricStore.SaveError(uri, ErrLyricsNotFound)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		LyricStore.Save(uri, []LyricLine{})
-		return nil, fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
+		return nil, LyricStore.SaveError(uri, fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode))
 	}
 
 	var resJson LrcLibResponse
 	err = json.NewDecoder(resp.Body).Decode(&resJson)
 	if err != nil {
-		LyricStore.Save(uri, []LyricLine{})
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, LyricStore.SaveError(uri, fmt.Errorf("failed to read response body: %w", err))
 	}
 
-	lyrics, err := ParseLyrics(resJson.SyncedLyrics)
+	if resJson.Instrumental {
+		return nil, LyricStore.SaveError(uri, ErrInstrumental)
+	}
+	if strings.TrimSpace(resJson.SyncedLyrics) == "" && strings.TrimSpace(resJson.PlainLyrics) != "" {
+		return nil, LyricStore.SaveError(uri, ErrUnsynced)
+	}
+
+	lyrics, metadata, err := ParseLyrics(resJson.SyncedLyrics)
 	if err != nil {
-		LyricStore.Save(uri, []LyricLine{})
-		return nil, fmt.Errorf("failed to parse lyrics: %w", err)
+		return nil, LyricStore.SaveError(uri, fmt.Errorf("failed to parse lyrics: %w", err))
 	}
 
 	if len(lyrics) == 0 {
-		LyricStore.Save(uri, []LyricLine{})
-		return nil, fmt.Errorf("failed to find sync lyrics lines")
+		return nil, LyricStore.SaveError(uri, fmt.Errorf("failed to find sync lyrics lines"))
 	}
 
-	slices.SortFunc(lyrics, func(a, b LyricLine) int {
-		return int((a.Timestamp - b.Timestamp) / time.Millisecond)
-	})
+	// Keep the track information of LrcLib for exporting
+	for key, value := range map[string]string{
+		"ti":     resJson.TrackName,
+		"ar":     resJson.ArtistName,
+		"al":     resJson.AlbumName,
+		"length": FormatTimestamp(time.Duration(resJson.Duration * float64(time.Second))),
+	} {
+		if _, exists := metadata[key]; !exists && value != "" {
+			metadata[key] = value
+		}
+	}
 
-	if err = SaveCache(lyrics, cacheFile); err != nil {
+	if err = SaveCache(lyrics, metadata, cacheFile); err != nil {
 		return nil, fmt.Errorf("failed to cache lyrics to psudo csv: %w", err)
 	}
 
+	lyrics = RomanizeLyrics(lyrics)
 	LyricStore.Save(uri, lyrics)
 
 	return lyrics, nil
 }
+
+// LyricsCached reports whether the lyrics of info are in the memory or the
+// disk cache
+func LyricsCached(info *PlayerInfo) bool {
+	uri := LyricsKey(info)
+	if lyrics, ok := LyricStore.Load(uri); ok && len(lyrics) != 0 {
+		return true
+	}
+	_, err := os.Stat(lyricsCacheFile(info))
+	return err == nil
+}
+
+// ForgetLyrics removes the lyrics of info from the memory and the disk cache,
+// so GetLyrics fetches them again
+func ForgetLyrics(info *PlayerInfo) error {
+	uri := LyricsKey(info)
+	LyricStore.Delete(uri)
+
+	err := os.Remove(lyricsCacheFile(info))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -2,41 +2,76 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Nadim147c/go-mpris"
 	"github.com/godbus/dbus/v5"
+	"github.com/spf13/pflag"
 )
 
 const (
 	SleepTime = 500 * time.Millisecond
 	Version   = "waybar-lyric v0.8.0 (https://github.com/Nadim147c/waybar-lyric)"
+
+	// DriftInterval is how often the player is asked for its properties to
+	// correct the extrapolated position
+	DriftInterval = 5 * time.Second
 )
 
 func truncate(input string) string {
-	r := []rune(input)
+	return Truncate(input, MaxTextLength, TruncateWords)
+}
 
-	if len(r) <= MaxTextLength {
-		return input
+func main() {
+	if TooltipLines < 4 {
+		fmt.Fprintln(os.Stderr, "Tooltip lines limit must be at least 4")
+		return
 	}
 
-	if MaxTextLength > 3 {
-		return string(r[:MaxTextLength-3]) + "..."
+	if !slices.Contains(TranslationModes, Translation) {
+		fmt.Fprintf(os.Stderr, "Translation must be one of %s\n", strings.Join(TranslationModes, ", "))
+		return
 	}
 
-	return string(r[:MaxTextLength])
-}
+	for _, script := range Romanize {
+		if !slices.Contains(RomanizeScripts, script) {
+			fmt.Fprintf(os.Stderr, "Romanize scripts must be %s\n", strings.Join(RomanizeScripts, ", "))
+			return
+		}
+	}
 
-func main() {
-	if TooltipLines < 4 {
-		fmt.Fprintln(os.Stderr, "Tooltip lines limit must be at least 4")
+	if !slices.Contains(CyrillicSchemes, CyrillicScheme) {
+		fmt.Fprintf(os.Stderr, "Cyrillic scheme must be one of %s\n", strings.Join(CyrillicSchemes, ", "))
+		return
+	}
+
+	if MarqueeMode && SplitLines {
+		fmt.Fprintln(os.Stderr, "Marquee and split modes can't be used together")
+		return
+	}
+
+	sink, err := NewSink(Output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Output must be one of %s\n", strings.Join(OutputFormats, ", "))
+		return
+	}
+	OutputSink = sink
+	if !sink.Markup() {
+		NoMarkup = true
+	}
+
+	if err := ParseTemplates(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		return
 	}
 
@@ -50,31 +85,97 @@ func main() {
 		return
 	}
 
+	if ClientMode && !ToggleState && pflag.NArg() == 0 {
+		if err := RunClient(); err != nil {
+			slog.Error("Failed to connect to daemon", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	tui := false
+	switch pflag.Arg(0) {
+	case "":
+	case "tui":
+		// The main loop tracks the player for the TUI without output
+		OutputWriter = io.Discard
+		if LogFilePath == "" {
+			slog.SetDefault(slog.New(slog.DiscardHandler))
+		}
+		tui = true
+	case "daemon":
+		stopDaemon, err := StartDaemon()
+		if err != nil {
+			slog.Error("Failed to start daemon", "error", err)
+			os.Exit(1)
+		}
+		defer stopDaemon()
+	case "prefetch":
+		if pflag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: waybar-lyric prefetch <playlist|csv|directory>")
+			os.Exit(1)
+		}
+		if err := Prefetch(pflag.Arg(1)); err != nil {
+			slog.Error("Failed to prefetch lyrics", "error", err)
+			os.Exit(1)
+		}
+		return
+	case "publish":
+		if pflag.NArg() > 2 {
+			fmt.Fprintln(os.Stderr, "Usage: waybar-lyric publish [file.lrc|artist - title]")
+			os.Exit(1)
+		}
+		if err := Publish(pflag.Arg(1)); err != nil {
+			slog.Error("Failed to publish lyrics", "error", err)
+			os.Exit(1)
+		}
+		return
+	case "lint":
+		if pflag.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: waybar-lyric lint <file>...")
+			os.Exit(1)
+		}
+		if !Lint(pflag.Args()[1:]) {
+			os.Exit(1)
+		}
+		return
+	case "offset":
+		if err := RunOffset(pflag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, "Usage: waybar-lyric offset [global|player|track] [+100ms|-100ms|250ms|reset]")
+			os.Exit(1)
+		}
+		return
+	case "export":
+		if pflag.NArg() > 2 {
+			fmt.Fprintln(os.Stderr, "Usage: waybar-lyric export [file|artist - title] [--format lrc|srt|vtt|json]")
+			os.Exit(1)
+		}
+		if err := Export(pflag.Arg(1)); err != nil {
+			slog.Error("Failed to export lyrics", "error", err)
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", pflag.Arg(0))
+		pflag.Usage()
+		os.Exit(1)
+	}
+
 	conn, err := dbus.SessionBus()
 	if err != nil {
 		slog.Error("Failed to create dbus connection", "error", err)
 		return
 	}
 
-	// Call ListNames on org.freedesktop.DBus
-	var names []string
-	err = conn.Object("org.freedesktop.DBus", "/org/freedesktop/DBus").
-		Call("org.freedesktop.DBus.ListNames", 0).
-		Store(&names)
-	if err != nil {
+	player, err := FindPlayer(conn)
+	if err != nil && (ToggleState || !errors.Is(err, ErrNoPlayer)) {
 		log.Fatal(err)
 	}
-
-	// Filter MPRIS players
-	var playerName string
-	for _, name := range names {
-		if len(name) >= len("org.mpris.MediaPlayer2.") && name[:len("org.mpris.MediaPlayer2.")] == "org.mpris.MediaPlayer2." {
-			playerName = name
-		}
+	if err != nil {
+		player = waitForPlayer(conn)
 	}
 
-	player := mpris.New(conn, playerName)
-
 	if ToggleState {
 		slog.Info("Toggling player state")
 		if err := player.PlayPause(); err != nil {
@@ -83,6 +184,21 @@ func main() {
 		return
 	}
 
+	UpdateState(EventState, func(s *State) { s.Player = player.GetName() })
+	if err := StartService(conn); err != nil {
+		slog.Warn("Failed to start D-Bus service", "error", err)
+	}
+	if NotifyTrack || LyricOSD {
+		StartNotifier(conn)
+	}
+	StartHooks()
+	if ServeAddr != "" {
+		if err := StartServer(ServeAddr); err != nil {
+			slog.Error("Failed to serve lyrics", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -93,19 +209,59 @@ func main() {
 		cancel()
 	}()
 
-	psChan := make(chan *dbus.Signal, 0)
-	player.OnSignal(psChan)
+	if tui {
+		tuiDone := make(chan struct{})
+		go func() {
+			defer close(tuiDone)
+			defer cancel()
+			if err := RunTUI(ctx, conn); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+		defer func() { <-tuiDone }()
+	}
 
-	lyricTicker := time.NewTicker(SleepTime)
-	defer lyricTicker.Stop()
+	psChan := make(chan *dbus.Signal, 16)
+	conn.Signal(psChan)
+
+	tracker := NewTracker(conn, player)
+	activeTracker.Store(tracker)
+	// follow replaces the tracker with one of the player
+	follow := func(name string) {
+		slog.Info("Following player", "name", name)
+		tracker.Close()
+		player = mpris.New(conn, name)
+		tracker = NewTracker(conn, player)
+		activeTracker.Store(tracker)
+		UpdateState(EventState, func(s *State) { s.Player = name })
+	}
+
+	offsets, err := LoadOffsets()
+	if err != nil {
+		slog.Error("Failed to load offsets", "error", err)
+	}
+	// OffsetsChanged is received on psChan with the player signals
+	err = conn.AddMatchSignal(dbus.WithMatchInterface(ServiceName), dbus.WithMatchMember("OffsetsChanged"))
+	if err != nil {
+		slog.Warn("Failed to watch offset changes", "error", err)
+	}
+
+	// lyricTimer fires at the next line, word, chunk or translation switch
+	lyricTimer := time.NewTimer(0)
+	defer lyricTimer.Stop()
 
 	// Main loop
-	fixedTicker := time.NewTicker(SleepTime)
-	defer fixedTicker.Stop()
+	driftTicker := time.NewTicker(DriftInterval)
+	defer driftTicker.Stop()
 
 	var lastInfo *PlayerInfo = nil
 	var lastLine *LyricLine = nil
+	var lastWord = -1
+	var lastTranslated bool
+	var lastFrame = -1
 	var lyricsNotFound bool
+	var lyricsLoaded bool
+	var offset time.Duration
 
 	playerOpened := true
 
@@ -113,40 +269,116 @@ func main() {
 		select {
 		case <-ctx.Done():
 			return // Clean exit on cancel
-		case <-psChan:
-			slog.Debug("Received player update signal")
-		case <-lyricTicker.C:
-		case <-fixedTicker.C:
+		case sig := <-psChan:
+			if sig.Name == OffsetsChanged {
+				if offsets, err = LoadOffsets(); err != nil {
+					slog.Error("Failed to load offsets", "error", err)
+				}
+				lastLine = nil
+				break
+			}
+			if !tracker.Handle(sig) {
+				continue
+			}
+			slog.Debug("Received player update signal", "signal", sig.Name)
+		case <-lyricTimer.C:
+		case <-driftTicker.C:
+			tracker.Check()
+		case cmd := <-Commands:
+			if cmd.Player != "" {
+				follow(cmd.Player)
+				lastInfo = nil
+			}
+			if cmd.Offset != 0 && lastInfo == nil {
+				slog.Warn("Dropped offset without a track", "offset", cmd.Offset)
+			}
+			if cmd.Offset != 0 && lastInfo != nil {
+				key := LyricsKey(lastInfo)
+				updated, err := UpdateOffsets(conn, func(o *Offsets) error {
+					o.Tracks[key] += cmd.Offset.Milliseconds()
+					return nil
+				})
+				if err != nil {
+					slog.Error("Failed to save offsets", "error", err)
+				} else {
+					offsets = updated
+				}
+			}
+			if cmd.Refetch && lastInfo != nil {
+				slog.Info("Refetching lyrics", "title", lastInfo.Title)
+				if err := ForgetLyrics(lastInfo); err != nil {
+					slog.Error("Failed to remove cached lyrics", "error", err)
+				}
+				lyricsNotFound, lyricsLoaded = false, false
+			}
+			lastLine = nil
 		}
 
-		if _, err := player.GetPosition(); err != nil {
+		info, err := tracker.Info()
+		if errors.Is(err, ErrPlayerGone) {
+			// Another player may have started while this one is gone
+			if next, findErr := FindPlayer(conn); findErr == nil && next.GetName() != player.GetName() {
+				follow(next.GetName())
+				lastInfo = nil
+				info, err = tracker.Info()
+			}
+		}
+		if errors.Is(err, ErrPlayerGone) {
+			lyricTimer.Stop()
 			if playerOpened {
 				slog.Error("Player not found!", "error", err)
-				fmt.Println("{}")
+				ClearOutput()
 				playerOpened = false
+				lastInfo = nil
+				UpdateState(EventState, func(s *State) {
+					s.Status, s.Track, s.Lyrics, s.Index, s.Provider = "", nil, nil, -1, ""
+					s.LyricsStatus = ""
+				})
 			}
 			continue
-		} else {
-			playerOpened = true
 		}
+		playerOpened = true
 
-		info, err := GetSpotifyInfo(player)
 		if err != nil {
 			slog.Error("Failed to parse dbus mpris metadata", "error", err)
-			fmt.Println("{}")
+			lyricTimer.Stop()
+			ClearOutput()
 			continue
 		}
 
-		playerUpdated := lastInfo == nil || lastInfo.ID != info.ID || lastInfo.Status != info.Status
+		if total := offsets.Total(player.GetName(), info); total != offset {
+			offset = total
+			slog.Info("Lyrics offset changed", "offset", offset)
+			UpdateState(EventOffset, func(s *State) { s.Offset = offset })
+		}
+		info.Position += offset
+
+		trackChanged := lastInfo == nil || lastInfo.ID != info.ID
+		statusChanged := lastInfo == nil || lastInfo.Status != info.Status
+		playerUpdated := trackChanged || statusChanged
 
 		if playerUpdated {
 			slog.Info("Player media found", "title", info.Title, "artist", info.Artist, "status", info.Status)
 			lastInfo = info
 		}
+		if trackChanged {
+			lyricsNotFound, lyricsLoaded = false, false
+			UpdateState(EventTrack, func(s *State) {
+				s.Track, s.Lyrics, s.Index, s.Provider = info, nil, -1, ""
+				s.LyricsStatus = ""
+			})
+		}
+		if statusChanged {
+			UpdateState(EventState, func(s *State) { s.Status = statusName(info) })
+		}
+
+		if info.Status != mpris.PlaybackPlaying {
+			lyricTimer.Stop()
+		}
 
 		if info.Status == mpris.PlaybackStopped {
 			slog.Info("Player is stopped")
-			fmt.Println("{}")
+			ClearOutput()
 			continue
 		}
 
@@ -158,16 +390,43 @@ func main() {
 			continue
 		}
 
+		cached := false
+		if !lyricsLoaded && !lyricsNotFound {
+			cached = LyricsCached(info)
+		}
+
 		lyrics, err := GetLyrics(info)
 		if err != nil {
+			lyricTimer.Stop()
 			if !lyricsNotFound {
 				slog.Error("Failed to get lyrics", "error", err)
-				info.Waybar().Encode()
+				waybar := info.Waybar()
+				if NoLyricsTemplate != nil {
+					data := NewTemplateData(nil, -1, info)
+					data.Text = waybar.Text
+					renderTemplate(&waybar.Text, NoLyricsTemplate, data)
+				}
+				waybar.Encode()
 				lyricsNotFound = true
+				UpdateState(EventLyrics, func(s *State) {
+					s.Lyrics, s.Index, s.Provider = nil, -1, ""
+					s.LyricsStatus = lyricsStatus(err)
+				})
 			}
 			continue
 		}
 		lyricsNotFound = false
+		if !lyricsLoaded {
+			lyricsLoaded = true
+			status, provider := LyricsFetched, ProviderLrcLib
+			if cached {
+				status, provider = LyricsFromCache, ProviderCache
+			}
+			UpdateState(EventLyrics, func(s *State) {
+				s.Lyrics, s.Index, s.Provider = lyrics, -1, provider
+				s.LyricsStatus = status
+			})
+		}
 
 		idx := -1
 		for i, line := range lyrics {
@@ -178,54 +437,111 @@ func main() {
 		}
 
 		if idx == -1 {
+			// Wake up for the first line
+			lyricTimer.Reset(max(tracker.WallTime(lyrics[0].Timestamp-info.Position), time.Millisecond))
+
 			if lastLine != nil && lastLine.Timestamp == -1 {
 				continue
 			}
 			lastLine = &LyricLine{Timestamp: -1, Text: ""}
+			UpdateState(EventLine, func(s *State) { s.Track, s.Index = info, -1 })
 
 			var tooltip strings.Builder
-			tooltip.WriteString("<b><big>󰝚 </big></b>\n")
-			tooltip.WriteString(fmt.Sprintf("<span foreground=\"%s\">", TootlipColor))
+			tooltip.WriteString(markupTag("b", markupTag("big", "󰝚 ")) + "\n")
 
 			end := min(TooltipLines, len(lyrics))
 			tooltipLyrics := lyrics[:end]
 			for _, ttl := range tooltipLyrics {
-				if ttl.Text != "" {
-					tooltip.WriteString(ttl.Text + "\n")
-				} else {
-					tooltip.WriteString("󰝚 \n")
+				text := "󰝚 "
+				if t := tooltipText(ttl); t != "" {
+					text = isolate(escapeMarkup(t), TextDirection(t))
 				}
+				tooltip.WriteString(colored(TootlipColor, text) + "\n")
+				tooltip.WriteString(tooltipTranslation(ttl))
 			}
 
 			waybar := info.Waybar()
-			waybar.Tooltip = strings.TrimSpace(tooltip.String()) + "</span>"
+			waybar.Tooltip = strings.TrimSpace(tooltip.String())
 			waybar.Alt = Music
-			waybar.Class = Class{Playing, Music}
+			waybar.Class = Class{Playing, Music, TextDirection(waybar.Text)}
+
+			if WaitingTemplate != nil || TooltipTemplate != nil {
+				data := NewTemplateData(lyrics, -1, info)
+				data.Text, data.Tooltip = waybar.Text, waybar.Tooltip
+				renderTemplate(&waybar.Text, WaitingTemplate, data)
+				renderTemplate(&waybar.Tooltip, TooltipTemplate, data)
+			}
 			waybar.Encode()
 		} else {
 			lyric := lyrics[idx]
-			if lastLine != nil && lastLine.Timestamp == lyric.Timestamp {
+
+			word := -1
+			if Karaoke {
+				word = lyric.WordIndex(info.Position)
+			}
+
+			translated := lyric.ShowsTranslation(info.Position)
+
+			window, windowed := lineWindow(lyrics, idx, displayText(lyric, info.Position), info.Position)
+			frame := -1
+			if windowed {
+				frame = window.Index
+			}
+
+			next, hasNext := time.Duration(0), false
+			if len(lyrics) > idx+1 {
+				next, hasNext = lyrics[idx+1].Timestamp, true
+			}
+
+			// Update at the next word boundary in karaoke mode
+			if nextWord, ok := lyric.NextWord(info.Position); Karaoke && ok && (!hasNext || nextWord < next) {
+				next, hasNext = nextWord, true
+			}
+
+			// Switch between text and translation in alternate mode
+			if nextSwitch, ok := lyric.NextTranslationSwitch(info.Position); ok && (!hasNext || nextSwitch < next) {
+				next, hasNext = nextSwitch, true
+			}
+
+			// Scroll the marquee or show the next chunk without delaying the next line
+			if windowed && window.Next != 0 && (!hasNext || window.Next < next) {
+				next, hasNext = window.Next, true
+			}
+
+			// The timer is set on every wake up, because the player may have
+			// moved since it was set
+			if hasNext {
+				d := max(tracker.WallTime(next-info.Position), time.Millisecond)
+				slog.Debug("Sleep", "duration", d.String(), "position", info.Position.String(), "next", next.String())
+				lyricTimer.Reset(d)
+			} else {
+				lyricTimer.Stop()
+			}
+
+			lineChanged := lastLine == nil || lastLine.Timestamp != lyric.Timestamp
+			if !lineChanged && lastWord == word && lastTranslated == translated && lastFrame == frame {
 				continue
 			}
 			lastLine = &lyric
+			lastWord = word
+			lastTranslated = translated
+			lastFrame = frame
 
-			slog.Info("Lyrics", "line", lyric.Text)
+			if lineChanged {
+				slog.Info("Lyrics", "line", lyric.Text)
+				UpdateState(EventLine, func(s *State) { s.Track, s.Index = info, idx })
+			}
 
-			waybar := NewWaybar(lyrics, idx, info.Percentage())
+			waybar := NewWaybar(lyrics, idx, info)
 			if lyric.Text != "" {
 				waybar.Encode()
 			} else {
-				waybar.Text = fmt.Sprintf("%s - %s", info.Artist, info.Title)
+				music := info.Waybar()
+				waybar.Text = music.Text
+				waybar.Class = Class{Lyric, Playing, TextDirection(music.Text)}
 				waybar.Alt = Music
 				waybar.Encode()
 			}
-
-			if len(lyrics) > idx+1 {
-				n := lyrics[idx+1]
-				d := n.Timestamp - info.Position
-				slog.Debug("Sleep", "duration", d.String(), "position", info.Position.String(), "next", n.Timestamp.String())
-				lyricTicker.Reset(d)
-			}
 		}
 
 	}
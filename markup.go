@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// markupEscaper escapes the characters which Pango reads as markup
+var markupEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&apos;",
+)
+
+// escapeMarkup escapes text from lyrics or the player before it is put in
+// markup. Text is kept as is with --no-markup.
+func escapeMarkup(text string) string {
+	if NoMarkup {
+		return text
+	}
+	return markupEscaper.Replace(text)
+}
+
+// markupUnescaper reverts markupEscaper
+var markupUnescaper = strings.NewReplacer(
+	"&amp;", "&",
+	"&lt;", "<",
+	"&gt;", ">",
+	"&quot;", `"`,
+	"&apos;", "'",
+)
+
+// unescapeMarkup returns the text of escapeMarkup
+func unescapeMarkup(text string) string {
+	if NoMarkup {
+		return text
+	}
+	return markupUnescaper.Replace(text)
+}
+
+// markupTags matches the tags of Pango markup
+var markupTags = regexp.MustCompile(`<[^>]*>`)
+
+// stripMarkup returns the text of Pango markup
+func stripMarkup(markup string) string {
+	return html.UnescapeString(markupTags.ReplaceAllString(markup, ""))
+}
+
+// markupTag wraps markup in a Pango tag, e.g. "b" or "small". markup is
+// returned as is with --no-markup.
+func markupTag(tag, markup string) string {
+	if NoMarkup {
+		return markup
+	}
+	return fmt.Sprintf("<%s>%s</%s>", tag, markup, tag)
+}
+
+// colored wraps markup in a span with the foreground color. markup is
+// returned as is with --no-markup.
+func colored(color, markup string) string {
+	if NoMarkup || markup == "" {
+		return markup
+	}
+	return fmt.Sprintf("<span foreground=\"%s\">%s</span>", escapeMarkup(color), markup)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeMarkup(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "Rock & Roll", want: "Rock &amp; Roll"},
+		{input: "I <3 you", want: "I &lt;3 you"},
+		{input: "a > b", want: "a &gt; b"},
+		{input: `"Quoted" isn't`, want: "&quot;Quoted&quot; isn&apos;t"},
+		{input: "&amp;", want: "&amp;amp;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := escapeMarkup(tt.input); got != tt.want {
+				t.Errorf("escapeMarkup(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWaybarMarkup(t *testing.T) {
+	lyrics := []LyricLine{
+		{Timestamp: 1 * time.Second, Text: "Rock & Roll <3", Translation: `"Rock" > 'Roll'`},
+		{Timestamp: 20 * time.Second, Text: "<b>not bold</b>"},
+	}
+
+	defer func(noMarkup, karaoke bool) { NoMarkup, Karaoke = noMarkup, karaoke }(NoMarkup, Karaoke)
+	Karaoke = true
+
+	tests := []struct {
+		name        string
+		noMarkup    bool
+		wantText    string
+		wantTooltip []string
+	}{
+		{
+			name:     "Markup",
+			wantText: "Rock &amp; Roll &lt;3",
+			wantTooltip: []string{
+				"<b><big>Rock &amp; Roll &lt;3</big></b>",
+				"<small>&quot;Rock&quot; &gt; &apos;Roll&apos;</small>",
+				"&lt;b&gt;not bold&lt;/b&gt;",
+			},
+		},
+		{
+			name:        "No markup",
+			noMarkup:    true,
+			wantText:    "Rock & Roll <3",
+			wantTooltip: []string{"Rock & Roll <3\n\"Rock\" > 'Roll'\n<b>not bold</b>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			NoMarkup = tt.noMarkup
+			waybar := NewWaybar(lyrics, 0, &PlayerInfo{Position: time.Second})
+			if got := stripIsolates.Replace(waybar.Text); got != tt.wantText {
+				t.Errorf("NewWaybar().Text = %q, want %q", got, tt.wantText)
+			}
+
+			tooltip := stripIsolates.Replace(waybar.Tooltip)
+			for _, want := range tt.wantTooltip {
+				if !strings.Contains(tooltip, want) {
+					t.Errorf("NewWaybar().Tooltip = %q, want it to contain %q", tooltip, want)
+				}
+			}
+			if tt.noMarkup && strings.Contains(tooltip, "<span") {
+				t.Errorf("NewWaybar().Tooltip = %q, want no markup", tooltip)
+			}
+		})
+	}
+}
+
+func TestPlayerInfoWaybarMarkup(t *testing.T) {
+	info := &PlayerInfo{Artist: "Simon & Garfunkel", Title: `"Cecilia" <live>`}
+	want := "Simon &amp; Garfunkel - &quot;Cecilia&quot; &lt;live&gt;"
+	if got := stripIsolates.Replace(info.Waybar().Text); got != want {
+		t.Errorf("PlayerInfo.Waybar().Text = %q, want %q", got, want)
+	}
+}
+
+func TestStripMarkup(t *testing.T) {
+	markup := `<span foreground="#1db954">Rock &amp;</span> Roll &lt;3 &quot;x&quot;`
+	if got, want := stripMarkup(markup), `Rock & Roll <3 "x"`; got != want {
+		t.Errorf("stripMarkup() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Window is the part of a long line shown in the bar by the marquee or as a
+// chunk of a split line
+type Window struct {
+	// Index of the frame in the scroll or of the chunk
+	Index int
+	// Start and End are the rune offsets of the window in the text
+	Start, End int
+	// Next is the position of the next window. It is zero after the last one.
+	Next time.Duration
+}
+
+// marqueeSteps returns the rune offsets of the windows of text which fit in
+// width cells. It returns nil when text isn't wider than width.
+func marqueeSteps(text string, width int) [][2]int {
+	if StringWidth(text) <= width {
+		return nil
+	}
+
+	clusters := Graphemes(text)
+	var steps [][2]int
+	start := 0
+	for i := range clusters {
+		used, end := 0, start
+		for _, cluster := range clusters[i:] {
+			w := clusterWidth(cluster)
+			if used+w > width {
+				break
+			}
+			used += w
+			end += utf8.RuneCountInString(cluster)
+		}
+		steps = append(steps, [2]int{start, end})
+
+		// The last window shows the end of the text
+		if end == utf8.RuneCountInString(text) {
+			break
+		}
+		start += utf8.RuneCountInString(clusters[i])
+	}
+	return steps
+}
+
+// Marquee returns the frame of text shown elapsed after the line started.
+// The scroll pauses MarqueePauseStart at the start and MarqueePauseEnd at the
+// end and moves MarqueeSpeed characters per second. When the scroll doesn't fit in
+// duration, the time until the next line, everything is sped up to fit. The
+// scroll starts again after the end pause. It returns false when marquee mode
+// is off or text fits in MaxTextLength.
+func Marquee(text string, elapsed, duration time.Duration) (Window, bool) {
+	if !MarqueeMode || MaxTextLength <= 0 {
+		return Window{}, false
+	}
+
+	steps := marqueeSteps(text, MaxTextLength)
+	if len(steps) < 2 {
+		return Window{}, false
+	}
+
+	interval := time.Second
+	if MarqueeSpeed > 0 {
+		interval = time.Duration(float64(time.Second) / MarqueeSpeed)
+	}
+	// The first and the last frame are shown at least as long as the others
+	pauseStart, pauseEnd := max(MarqueePauseStart, interval), max(MarqueePauseEnd, interval)
+	between := time.Duration(len(steps) - 2)
+
+	cycle := pauseStart + between*interval + pauseEnd
+	if duration > 0 && cycle > duration {
+		scale := float64(duration) / float64(cycle)
+		interval = max(time.Duration(float64(interval)*scale), time.Millisecond)
+		pauseStart = max(time.Duration(float64(pauseStart)*scale), time.Millisecond)
+		pauseEnd = max(time.Duration(float64(pauseEnd)*scale), time.Millisecond)
+		cycle = pauseStart + between*interval + pauseEnd
+	}
+
+	elapsed = max(elapsed, 0)
+	since := elapsed % cycle
+	cycleStart := elapsed - since
+
+	// Frame 0 is shown during the start pause, the frames in between for an
+	// interval each and the last frame during the end pause
+	last := len(steps) - 1
+	index := 0
+	next := pauseStart
+	if since >= pauseStart {
+		index = min(int((since-pauseStart)/interval)+1, last)
+		next = pauseStart + time.Duration(index)*interval
+		if index == last {
+			next = cycle
+		}
+	}
+
+	frame := Window{
+		Index: index,
+		Start: steps[index][0],
+		End:   steps[index][1],
+		Next:  cycleStart + next,
+	}
+	return frame, true
+}
+
+// lineWindow returns the window of text, the text shown for lyrics[idx], at
+// position in split or marquee mode
+func lineWindow(lyrics []LyricLine, idx int, text string, position time.Duration) (Window, bool) {
+	var duration time.Duration
+	if idx+1 < len(lyrics) {
+		duration = lyrics[idx+1].Timestamp - lyrics[idx].Timestamp
+	}
+
+	var window Window
+	var ok bool
+	if SplitLines {
+		window, ok = Chunk(lyrics[idx], text, position-lyrics[idx].Timestamp, duration)
+	} else {
+		window, ok = Marquee(text, position-lyrics[idx].Timestamp, duration)
+	}
+
+	if ok && window.Next != 0 {
+		window.Next += lyrics[idx].Timestamp
+	}
+	return window, ok
+}
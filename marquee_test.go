@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func withMarquee(t *testing.T, width int, speed float64, pauseStart, pauseEnd time.Duration) {
+	t.Helper()
+	mode, w, s, ps, pe := MarqueeMode, MaxTextLength, MarqueeSpeed, MarqueePauseStart, MarqueePauseEnd
+	t.Cleanup(func() {
+		MarqueeMode, MaxTextLength, MarqueeSpeed, MarqueePauseStart, MarqueePauseEnd = mode, w, s, ps, pe
+	})
+	MarqueeMode, MaxTextLength, MarqueeSpeed, MarqueePauseStart, MarqueePauseEnd = true, width, speed, pauseStart, pauseEnd
+}
+
+func TestMarquee(t *testing.T) {
+	withMarquee(t, 5, 10, time.Second, 500*time.Millisecond)
+
+	// "abcdefgh" has the windows abcde, bcdef, cdefg and defgh
+	tests := []struct {
+		name     string
+		elapsed  time.Duration
+		duration time.Duration
+		want     Window
+	}{
+		{name: "Start pause", elapsed: 0, want: Window{Index: 0, Start: 0, End: 5, Next: time.Second}},
+		{name: "Scrolling", elapsed: 1150 * time.Millisecond, want: Window{Index: 2, Start: 2, End: 7, Next: 1200 * time.Millisecond}},
+		{name: "End pause", elapsed: 1300 * time.Millisecond, want: Window{Index: 3, Start: 3, End: 8, Next: 1700 * time.Millisecond}},
+		{name: "Starts again", elapsed: 1800 * time.Millisecond, want: Window{Index: 0, Start: 0, End: 5, Next: 2700 * time.Millisecond}},
+		{
+			// The 1.7s scroll is sped up to fit in 850ms
+			name: "Fitted to the next line", elapsed: 550 * time.Millisecond, duration: 850 * time.Millisecond,
+			want: Window{Index: 2, Start: 2, End: 7, Next: 600 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Marquee("abcdefgh", tt.elapsed, tt.duration)
+			if !ok {
+				t.Fatal("Marquee() returned no frame")
+			}
+			if got != tt.want {
+				t.Errorf("Marquee() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarqueeWide(t *testing.T) {
+	withMarquee(t, 4, 10, 0, 0)
+
+	got, ok := Marquee("君の名は", 150*time.Millisecond, 0)
+	if want := (Window{Index: 1, Start: 1, End: 3, Next: 200 * time.Millisecond}); !ok || got != want {
+		t.Errorf("Marquee() = %+v, %v, want %+v", got, ok, want)
+	}
+
+	if _, ok := Marquee("短い", 0, 0); ok {
+		t.Error("Marquee() scrolled text which fits")
+	}
+}
+
+func TestNewWaybarMarquee(t *testing.T) {
+	withMarquee(t, 5, 10, time.Second, time.Second)
+
+	lyrics := []LyricLine{
+		{Timestamp: 10 * time.Second, Text: "abcdefgh"},
+		{Timestamp: 20 * time.Second, Text: "next"},
+	}
+	waybar := NewWaybar(lyrics, 0, &PlayerInfo{Position: 11150 * time.Millisecond})
+	if got := stripIsolates.Replace(waybar.Text); got != "cdefg" {
+		t.Errorf("NewWaybar().Text = %q, want %q", got, "cdefg")
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/Nadim147c/go-mpris"
 )
@@ -23,15 +25,80 @@ type LrcLibResponse struct {
 	SyncedLyrics string  `json:"syncedLyrics"`
 }
 
+// LyricWord is a word of a LyricLine with its own timestamp (enhanced LRC)
+type LyricWord struct {
+	Timestamp time.Duration
+	Text      string
+}
+
 // LyricLine is a line of synchronized lyrics
 type LyricLine struct {
 	Timestamp time.Duration
 	Text      string
+	Words     []LyricWord
+	// Voice is the singer of the line in duets, e.g. "v1"
+	Voice string
+	// Background is the background vocals sung along the line
+	Background *LyricLine
+	// Translation is the secondary text of the line, e.g. a translation or a
+	// romanization
+	Translation string
+	// Original is the text of the line before romanization
+	Original string
+}
+
+// WordIndex returns the index of the last word started at position or -1 if
+// the line doesn't have word timings
+func (l LyricLine) WordIndex(position time.Duration) int {
+	idx := -1
+	for i, word := range l.Words {
+		if position < word.Timestamp {
+			break
+		}
+		idx = i
+	}
+	return idx
+}
+
+// NextWord returns the timestamp of the first word after position
+func (l LyricLine) NextWord(position time.Duration) (time.Duration, bool) {
+	for _, word := range l.Words {
+		if position < word.Timestamp {
+			return word.Timestamp, true
+		}
+	}
+	return 0, false
+}
+
+// SungLength returns the number of runes of Text sung at word index idx
+func (l LyricLine) SungLength(idx int) int {
+	if idx < 0 || len(l.Words) == 0 {
+		return 0
+	}
+
+	var sung strings.Builder
+	for _, word := range l.Words[:min(idx+1, len(l.Words))] {
+		sung.WriteString(word.Text)
+	}
+	return utf8.RuneCountInString(strings.TrimLeftFunc(sung.String(), unicode.IsSpace))
 }
 
 // Lyrics is a slice of LyricLine
 type Lyrics []LyricLine
 
+// Metadata is the header tags of a LRC file, e.g. "ar", "ti" or "offset"
+type Metadata map[string]string
+
+// Offset returns the value of the offset tag. The tag is in milliseconds and a
+// positive value makes lyrics appear sooner.
+func (m Metadata) Offset() time.Duration {
+	ms, err := strconv.Atoi(strings.TrimPrefix(m["offset"], "+"))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
 // Status is the alt/class for waybar
 type Status string
 
@@ -40,6 +107,8 @@ const (
 	Lyric   Status = "lyric"
 	Playing Status = "playing"
 	Paused  Status = "paused"
+	RTL     Status = "rtl"
+	LTR     Status = "ltr"
 )
 
 type Class []Status
@@ -52,47 +121,129 @@ type Waybar struct {
 	Percentage int    `json:"percentage"`
 }
 
-func NewWaybar(lyrics []LyricLine, idx, percentage int) *Waybar {
+// TranslationModes are the values of the --translation flag
+var TranslationModes = []string{"none", "tooltip", "line", "alternate"}
+
+// ShowsTranslation reports whether the bar shows the translation of the line
+// instead of its text at position in the alternate translation mode
+func (l LyricLine) ShowsTranslation(position time.Duration) bool {
+	if Translation != "alternate" || l.Translation == "" || TranslationInterval <= 0 {
+		return false
+	}
+	return ((position-l.Timestamp)/TranslationInterval)%2 == 1
+}
+
+// NextTranslationSwitch returns when the alternate translation mode switches
+// between the text and the translation of the line
+func (l LyricLine) NextTranslationSwitch(position time.Duration) (time.Duration, bool) {
+	if Translation != "alternate" || l.Translation == "" || TranslationInterval <= 0 {
+		return 0, false
+	}
+	n := (position-l.Timestamp)/TranslationInterval + 1
+	return l.Timestamp + n*TranslationInterval, true
+}
+
+// tooltipText returns the text of line shown in the tooltip
+func tooltipText(line LyricLine) string {
+	if RomanizeTooltip && line.Original != "" {
+		return line.Original
+	}
+	return line.Text
+}
+
+// tooltipTranslation returns the tooltip line of the translation of line
+func tooltipTranslation(line LyricLine) string {
+	if Translation == "none" || line.Translation == "" {
+		return ""
+	}
+	translation := isolate(escapeMarkup(line.Translation), TextDirection(line.Translation))
+	return colored(TootlipColor, markupTag("small", translation)) + "\n"
+}
+
+// displayText returns the text of line shown in the bar at position
+func displayText(line LyricLine, position time.Duration) string {
+	if line.ShowsTranslation(position) {
+		return line.Translation
+	}
+	return line.Text
+}
+
+// karaoke escapes text and colors its first sung runes with KaraokeColor
+func karaoke(text string, sung int) string {
+	r := []rune(text)
+	sung = min(max(sung, 0), len(r))
+	return colored(KaraokeColor, escapeMarkup(string(r[:sung]))) + escapeMarkup(string(r[sung:]))
+}
+
+func NewWaybar(lyrics []LyricLine, idx int, info *PlayerInfo) *Waybar {
 	lyric := lyrics[idx]
+	position := info.Position
+
+	translated := lyric.ShowsTranslation(position)
+
+	sung := 0
+	if Karaoke && !translated {
+		sung = lyric.SungLength(lyric.WordIndex(position))
+	}
+
 	start := max(idx-2, 0)
 	end := min(idx+TooltipLines-2, len(lyrics))
 
 	tooltipLyrics := lyrics[start:end]
 	var tooltip strings.Builder
-
-	tooltip.WriteString(fmt.Sprintf("<span foreground=\"%s\">", TootlipColor))
-
 	for i, ttl := range tooltipLyrics {
-		line := ttl.Text
-		if ttl.Text == "" {
+		line := tooltipText(ttl)
+		if line == "" {
 			line = "󰝚 "
 		}
+		direction := TextDirection(line)
 
 		if start+i == idx {
-			newLine := fmt.Sprintf("</span><b><big>%s</big></b>\n<span foreground=\"%s\">", line, TootlipColor)
-			tooltip.WriteString(newLine)
+			if line == ttl.Text {
+				line = karaoke(line, sung)
+			} else {
+				line = escapeMarkup(line)
+			}
+			tooltip.WriteString(markupTag("b", markupTag("big", isolate(line, direction))) + "\n")
 		} else {
-			tooltip.WriteString(line + "\n")
+			tooltip.WriteString(colored(TootlipColor, isolate(escapeMarkup(line), direction)) + "\n")
 		}
+		tooltip.WriteString(tooltipTranslation(ttl))
+	}
+
+	// The truncation marker is added inside the isolate, so it stays at the
+	// logical end of right-to-left lines
+	text := displayText(lyric, position)
+	direction := TextDirection(text)
+
+	shown := truncate(text)
+	if frame, ok := lineWindow(lyrics, idx, text, position); ok {
+		shown = string([]rune(text)[frame.Start:frame.End])
+		sung = min(max(sung-frame.Start, 0), frame.End-frame.Start)
 	}
+	line := isolate(karaoke(shown, sung), direction)
+	if Translation == "line" && !translated && lyric.Translation != "" {
+		line += "\n" + isolate(escapeMarkup(truncate(lyric.Translation)), TextDirection(lyric.Translation))
+	}
+	tt := strings.TrimSpace(tooltip.String())
 
-	line := truncate(lyric.Text)
-	tt := strings.TrimSpace(tooltip.String()) + "</span>"
+	// The template data copies the lyrics, which is too slow for every frame
+	// of the default output
+	if TextTemplate != nil || TooltipTemplate != nil {
+		data := NewTemplateData(lyrics, idx, info)
+		data.Text, data.Tooltip = line, tt
+		renderTemplate(&line, TextTemplate, data)
+		renderTemplate(&tt, TooltipTemplate, data)
+	}
 
 	return &Waybar{
-		Alt: Lyric, Class: Class{Lyric, Playing},
+		Alt: Lyric, Class: Class{Lyric, Playing, direction},
 		Text:       line,
 		Tooltip:    tt,
-		Percentage: percentage,
+		Percentage: info.Percentage(),
 	}
 }
 
-func (w *Waybar) Encode() {
-	e := json.NewEncoder(os.Stdout)
-	e.SetEscapeHTML(false)
-	e.Encode(w)
-}
-
 type PlayerInfo struct {
 	ID     string
 	Artist string
@@ -106,6 +257,9 @@ type PlayerInfo struct {
 }
 
 func (p *PlayerInfo) Percentage() int {
+	if p.Length <= 0 {
+		return 0
+	}
 	return int((p.Position * 100) / p.Length)
 }
 
@@ -116,24 +270,71 @@ func (p *PlayerInfo) Waybar() *Waybar {
 	}
 
 	text := fmt.Sprintf("%s - %s", p.Artist, p.Title)
+	direction := TextDirection(text)
+	text = isolate(escapeMarkup(text), direction)
+
+	if alt == Paused && PausedTemplate != nil {
+		data := NewTemplateData(nil, -1, p)
+		data.Text = text
+		renderTemplate(&text, PausedTemplate, data)
+	}
 
 	return &Waybar{
-		Class:      Class{alt},
+		Class:      Class{alt, direction},
 		Text:       text,
 		Alt:        alt,
 		Percentage: p.Percentage(),
 	}
 }
 
-type Store map[string]Lyrics
+// Store is an in-memory lyrics cache which is safe for concurrent use
+type Store struct {
+	mu    sync.RWMutex
+	items map[string]Lyrics
+	errs  map[string]error
+}
+
+// NewStore creates an empty Store
+func NewStore() *Store {
+	return &Store{items: make(map[string]Lyrics), errs: make(map[string]error)}
+}
+
+// SaveError saves that the lyrics of key weren't found because of err and
+// returns err
+func (s *Store) SaveError(key string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = Lyrics{}
+	s.errs[key] = err
+	return err
+}
+
+// LoadError loads the error saved with SaveError
+func (s *Store) LoadError(key string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.errs[key]
+}
 
 // Save saves lyrics to Store
-func (s Store) Save(key string, value Lyrics) {
-	s[key] = value
+func (s *Store) Save(key string, value Lyrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = value
 }
 
 // Load loads lyrics from Store
-func (s Store) Load(key string) (Lyrics, bool) {
-	v, e := s[key]
+func (s *Store) Load(key string) (Lyrics, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, e := s.items[key]
 	return v, e
 }
+
+// Delete removes lyrics from Store
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	delete(s.errs, key)
+}
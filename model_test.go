@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWaybarTranslation(t *testing.T) {
+	lyrics := []LyricLine{
+		{Timestamp: 1 * time.Second, Text: "君の名は", Translation: "Your name"},
+		{Timestamp: 20 * time.Second, Text: "Next"},
+	}
+
+	defer func(mode string) { Translation = mode }(Translation)
+
+	tests := []struct {
+		mode     string
+		position time.Duration
+		wantText string
+	}{
+		{mode: "none", position: 2 * time.Second, wantText: "君の名は"},
+		{mode: "tooltip", position: 2 * time.Second, wantText: "君の名は"},
+		{mode: "line", position: 2 * time.Second, wantText: "君の名は\nYour name"},
+		{mode: "alternate", position: 2 * time.Second, wantText: "君の名は"},
+		{mode: "alternate", position: 6 * time.Second, wantText: "Your name"},
+		{mode: "alternate", position: 10 * time.Second, wantText: "君の名は"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			Translation = tt.mode
+			waybar := NewWaybar(lyrics, 0, &PlayerInfo{Position: tt.position})
+			if got := stripIsolates.Replace(waybar.Text); got != tt.wantText {
+				t.Errorf("NewWaybar().Text = %q, want %q", got, tt.wantText)
+			}
+
+			inTooltip := strings.Contains(waybar.Tooltip, "Your name")
+			if inTooltip != (tt.mode != "none") {
+				t.Errorf("NewWaybar().Tooltip = %q, translation shown = %v", waybar.Tooltip, inTooltip)
+			}
+		})
+	}
+}
+
+func TestNextTranslationSwitch(t *testing.T) {
+	defer func(mode string) { Translation = mode }(Translation)
+	Translation = "alternate"
+
+	line := LyricLine{Timestamp: 1 * time.Second, Text: "a", Translation: "b"}
+	got, ok := line.NextTranslationSwitch(6 * time.Second)
+	if want := 9 * time.Second; !ok || got != want {
+		t.Errorf("NextTranslationSwitch() = %v, %v, want %v", got, ok, want)
+	}
+
+	if _, ok := (LyricLine{Text: "a"}).NextTranslationSwitch(0); ok {
+		t.Error("NextTranslationSwitch() of a line without translation succeeded")
+	}
+}
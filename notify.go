@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notificationsName = "org.freedesktop.Notifications"
+	notificationsPath = dbus.ObjectPath("/org/freedesktop/Notifications")
+
+	// notifyQueue is the number of notifications waiting to be sent. New
+	// notifications are dropped when it is full.
+	notifyQueue = 8
+	// notifyTimeout is how long a track notification and the last line are
+	// shown
+	notifyTimeout = 5 * time.Second
+	// notifyIcon is the icon of the notifications
+	notifyIcon = "audio-x-generic"
+)
+
+// notification is a request to org.freedesktop.Notifications
+type notification struct {
+	// osd sends the notification in place of the last lyric notification
+	osd bool
+	// close closes the last lyric notification
+	close   bool
+	summary string
+	body    string
+	timeout time.Duration
+}
+
+// Notifier shows desktop notifications on track changes and, in OSD mode, a
+// notification per lyric line which replaces the one before
+type Notifier struct {
+	obj   dbus.BusObject
+	queue chan notification
+	// markup is true when the server reads markup in the body
+	markup bool
+
+	trackID uint32
+	osdID   uint32
+}
+
+// lyricsSummary describes how the lyrics of the track were found
+func lyricsSummary(status LyricsStatus) string {
+	switch status {
+	case LyricsFetched:
+		return "Lyrics found"
+	case LyricsFromCache:
+		return "Lyrics loaded from cache"
+	case LyricsUnsynced:
+		return "Only unsynced lyrics found"
+	case LyricsInstrumental:
+		return "Instrumental"
+	default:
+		return "Lyrics not found"
+	}
+}
+
+// send calls Notify and returns the id of the notification
+func (n *Notifier) send(replaces uint32, req notification, hints map[string]dbus.Variant) (uint32, error) {
+	body := req.body
+	if n.markup {
+		body = markupEscaper.Replace(body)
+	}
+
+	var id uint32
+	err := n.obj.Call(notificationsName+".Notify", 0,
+		"waybar-lyric", replaces, notifyIcon, req.summary, body,
+		[]string{}, hints, int32(req.timeout.Milliseconds()),
+	).Store(&id)
+	return id, err
+}
+
+// run sends the queued notifications
+func (n *Notifier) run() {
+	for req := range n.queue {
+		var err error
+		switch {
+		case req.close:
+			if n.osdID != 0 {
+				err = n.obj.Call(notificationsName+".CloseNotification", 0, n.osdID).Err
+				n.osdID = 0
+			}
+		case req.osd:
+			n.osdID, err = n.send(n.osdID, req, map[string]dbus.Variant{
+				"transient":         dbus.MakeVariant(true),
+				"urgency":           dbus.MakeVariant(byte(0)),
+				"x-dunst-stack-tag": dbus.MakeVariant("waybar-lyric-osd"),
+			})
+		default:
+			n.trackID, err = n.send(n.trackID, req, map[string]dbus.Variant{
+				"urgency":           dbus.MakeVariant(byte(0)),
+				"x-dunst-stack-tag": dbus.MakeVariant("waybar-lyric"),
+			})
+		}
+		if err != nil {
+			slog.Error("Failed to send notification", "error", err)
+		}
+	}
+}
+
+// push queues a notification without blocking the main loop
+func (n *Notifier) push(req notification) {
+	select {
+	case n.queue <- req:
+	default:
+		slog.Debug("Dropped notification", "summary", req.summary)
+	}
+}
+
+// handle turns state events into notifications
+func (n *Notifier) handle(event Event, s State) {
+	switch event {
+	case EventLyrics:
+		if !NotifyTrack || s.Track == nil {
+			return
+		}
+		n.push(notification{
+			summary: s.Track.Title,
+			body:    fmt.Sprintf("%s\n%s", s.Track.Artist, lyricsSummary(s.LyricsStatus)),
+			timeout: notifyTimeout,
+		})
+	case EventLine:
+		if !LyricOSD {
+			return
+		}
+		line := s.Line()
+		if line == nil || line.Text == "" {
+			n.push(notification{close: true})
+			return
+		}
+
+		// The line is shown until the next line starts
+		timeout := notifyTimeout
+		if next := s.Next(); next != nil {
+			// A timeout of 0 would never expire
+			timeout = max(next.Timestamp-line.Timestamp, 500*time.Millisecond)
+		}
+		req := notification{osd: true, summary: line.Text, timeout: timeout}
+		if Translation != "none" {
+			req.body = line.Translation
+		}
+		n.push(req)
+	case EventState, EventTrack:
+		if LyricOSD && s.Status != "playing" {
+			n.push(notification{close: true})
+		}
+	}
+}
+
+// StartNotifier shows notifications of the state with the notification server
+// on conn
+func StartNotifier(conn *dbus.Conn) {
+	n := &Notifier{
+		obj:   conn.Object(notificationsName, notificationsPath),
+		queue: make(chan notification, notifyQueue),
+	}
+
+	var capabilities []string
+	err := n.obj.Call(notificationsName+".GetCapabilities", 0).Store(&capabilities)
+	if err != nil {
+		slog.Warn("Failed to get notification server capabilities", "error", err)
+	}
+	n.markup = slices.Contains(capabilities, "body-markup")
+
+	go n.run()
+	Subscribe(n.handle)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLyricsStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want LyricsStatus
+	}{
+		{ErrInstrumental, LyricsInstrumental},
+		{ErrUnsynced, LyricsUnsynced},
+		{ErrLyricsNotFound, LyricsMissing},
+	}
+	for _, tt := range tests {
+		if got := lyricsStatus(tt.err); got != tt.want {
+			t.Errorf("lyricsStatus(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNotifierHandle(t *testing.T) {
+	defer func(track, osd bool) { NotifyTrack, LyricOSD = track, osd }(NotifyTrack, LyricOSD)
+	NotifyTrack, LyricOSD = true, true
+
+	n := &Notifier{queue: make(chan notification, notifyQueue)}
+	state := State{
+		Status:       "playing",
+		Track:        &PlayerInfo{Title: "Title", Artist: "Artist"},
+		Lyrics:       Lyrics{{Timestamp: time.Second, Text: "one"}, {Timestamp: 3 * time.Second}},
+		LyricsStatus: LyricsFromCache,
+		Index:        -1,
+	}
+
+	n.handle(EventLyrics, state)
+	if got := <-n.queue; got.summary != "Title" || got.body != "Artist\nLyrics loaded from cache" {
+		t.Errorf("track notification = %+v", got)
+	}
+
+	state.Index = 0
+	n.handle(EventLine, state)
+	if got := <-n.queue; !got.osd || got.summary != "one" || got.timeout != 2*time.Second {
+		t.Errorf("line notification = %+v", got)
+	}
+
+	// An empty line and a pause close the line notification
+	state.Index = 1
+	n.handle(EventLine, state)
+	state.Status = "paused"
+	n.handle(EventState, state)
+	for range 2 {
+		if got := <-n.queue; !got.close {
+			t.Errorf("notification = %+v, want close", got)
+		}
+	}
+
+	NotifyTrack, LyricOSD = false, false
+	n.handle(EventLyrics, state)
+	n.handle(EventLine, state)
+	if len(n.queue) != 0 {
+		t.Errorf("queued %d notifications with notifications disabled", len(n.queue))
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// OffsetsChanged is the signal emitted on the session bus after the offsets
+// file is saved, so running instances load it again
+const OffsetsChanged = ServiceName + ".OffsetsChanged"
+
+// Offsets are the lyrics offsets saved in the cache directory. They are in
+// milliseconds and added to the position of the player, so a positive offset
+// shows lyrics sooner.
+type Offsets struct {
+	Global int64 `json:"global"`
+	// Players are keyed by playerKey
+	Players map[string]int64 `json:"players,omitempty"`
+	// Tracks are keyed by LyricsKey
+	Tracks map[string]int64 `json:"tracks,omitempty"`
+}
+
+// OffsetsFile returns the path of the offsets file
+func OffsetsFile() string {
+	return filepath.Join(CacheDir, "offsets.json")
+}
+
+// playerKey returns the name of a player without the MPRIS prefix and the
+// instance suffix, e.g. "firefox" for org.mpris.MediaPlayer2.firefox.instance_1_2
+func playerKey(name string) string {
+	name = strings.TrimPrefix(name, "org.mpris.MediaPlayer2.")
+	name, _, _ = strings.Cut(name, ".instance")
+	return name
+}
+
+// Total returns the sum of the global offset and the offsets of the player and
+// the track. info can be nil.
+func (o Offsets) Total(player string, info *PlayerInfo) time.Duration {
+	ms := o.Global + o.Players[playerKey(player)]
+	if info != nil {
+		ms += o.Tracks[LyricsKey(info)]
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// LoadOffsets reads the offsets file. A missing file has no offsets.
+func LoadOffsets() (Offsets, error) {
+	var o Offsets
+	data, err := os.ReadFile(OffsetsFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return o, err
+	}
+	if err := json.Unmarshal(data, &o); err != nil {
+		return o, fmt.Errorf("invalid offsets file: %w", err)
+	}
+	return o, nil
+}
+
+// SaveOffsets writes the offsets file and emits OffsetsChanged on conn when
+// it isn't nil
+func SaveOffsets(conn *dbus.Conn, o Offsets) error {
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Running instances may read the file while it is written
+	tmp := OffsetsFile() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, OffsetsFile()); err != nil {
+		return err
+	}
+
+	if conn != nil {
+		return conn.Emit(ServicePath, OffsetsChanged)
+	}
+	return nil
+}
+
+// UpdateOffsets changes the offsets file with update. The file is read again
+// while it is locked, so running instances and the offset command don't
+// overwrite the changes of each other.
+func UpdateOffsets(conn *dbus.Conn, update func(o *Offsets) error) (Offsets, error) {
+	lock, err := lockFile(OffsetsFile()+".lock", syscall.LOCK_EX)
+	if err != nil {
+		return Offsets{}, err
+	}
+	defer lock.Close()
+
+	o, err := LoadOffsets()
+	if err != nil {
+		return o, err
+	}
+	if o.Players == nil {
+		o.Players = map[string]int64{}
+	}
+	if o.Tracks == nil {
+		o.Tracks = map[string]int64{}
+	}
+
+	if err := update(&o); err != nil {
+		return o, err
+	}
+	return o, SaveOffsets(conn, o)
+}
+
+// ParseOffset returns the offset value applied to current. Values with a sign
+// are added to current, values without a sign replace it and "reset" is 0.
+// Numbers without a unit are milliseconds.
+func ParseOffset(current time.Duration, value string) (time.Duration, error) {
+	if value == "reset" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		ms, numErr := strconv.ParseInt(value, 10, 64)
+		if numErr != nil {
+			return 0, fmt.Errorf("invalid offset %q, expected e.g. +100ms, -1s or 250", value)
+		}
+		d = time.Duration(ms) * time.Millisecond
+	}
+
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		return current + d, nil
+	}
+	return d, nil
+}
+
+// formatOffset formats d with its sign, e.g. "+100ms"
+func formatOffset(d time.Duration) string {
+	if d > 0 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
+
+// adjustOffset applies value to the offset in m saved under key
+func adjustOffset(m map[string]int64, key, value string) (time.Duration, error) {
+	d, err := ParseOffset(time.Duration(m[key])*time.Millisecond, value)
+	if err != nil {
+		return 0, err
+	}
+	if d == 0 {
+		delete(m, key)
+	} else {
+		m[key] = d.Milliseconds()
+	}
+	return d, nil
+}
+
+// RunOffset prints or changes the offsets of the offset command:
+//
+//	offset [global|player|track] [value]
+//
+// The scope defaults to the track. The player and the track are the ones
+// playing now.
+func RunOffset(args []string) error {
+	scope := "track"
+	if len(args) > 0 && (args[0] == "global" || args[0] == "player" || args[0] == "track") {
+		scope, args = args[0], args[1:]
+	}
+	// Flags after the command are read as arguments, see cil.go
+	for _, arg := range args {
+		if _, err := ParseOffset(0, arg); err != nil && strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("unknown offset %q, options go before the command, e.g. waybar-lyric %s offset", arg, arg)
+		}
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to create dbus connection: %w", err)
+	}
+
+	// The global offset doesn't need a player
+	var name string
+	var info *PlayerInfo
+	player, playerErr := FindPlayer(conn)
+	if playerErr == nil {
+		info, playerErr = GetSpotifyInfo(player)
+	}
+	if playerErr == nil {
+		name = player.GetName()
+	} else if scope != "global" {
+		return fmt.Errorf("failed to get track metadata: %w", playerErr)
+	}
+
+	var offsets Offsets
+	if len(args) == 1 {
+		offsets, err = UpdateOffsets(conn, func(o *Offsets) error {
+			var err error
+			switch scope {
+			case "global":
+				var d time.Duration
+				d, err = ParseOffset(time.Duration(o.Global)*time.Millisecond, args[0])
+				o.Global = d.Milliseconds()
+			case "player":
+				_, err = adjustOffset(o.Players, playerKey(name), args[0])
+			case "track":
+				_, err = adjustOffset(o.Tracks, LyricsKey(info), args[0])
+			}
+			return err
+		})
+	} else {
+		offsets, err = LoadOffsets()
+	}
+	if err != nil {
+		return err
+	}
+
+	ms := func(ms int64) string { return formatOffset(time.Duration(ms) * time.Millisecond) }
+	fmt.Printf("global: %s\n", ms(offsets.Global))
+	if name != "" {
+		fmt.Printf("player (%s): %s\n", playerKey(name), ms(offsets.Players[playerKey(name)]))
+		fmt.Printf("track (%s - %s): %s\n", info.Artist, info.Title, ms(offsets.Tracks[LyricsKey(info)]))
+		fmt.Printf("total: %s\n", formatOffset(offsets.Total(name, info)))
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseOffset(t *testing.T) {
+	current := 200 * time.Millisecond
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"+100ms", 300 * time.Millisecond},
+		{"-1s", -800 * time.Millisecond},
+		{"250ms", 250 * time.Millisecond},
+		{"-50", 150 * time.Millisecond},
+		{"400", 400 * time.Millisecond},
+		{"reset", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseOffset(current, tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseOffset(%s, %q) = %s, %v, want %s", current, tt.value, got, err, tt.want)
+		}
+	}
+
+	if _, err := ParseOffset(0, "soon"); err == nil {
+		t.Error("ParseOffset(\"soon\") succeeded, want an error")
+	}
+}
+
+func TestPlayerKey(t *testing.T) {
+	tests := map[string]string{
+		"org.mpris.MediaPlayer2.spotify":                 "spotify",
+		"org.mpris.MediaPlayer2.firefox.instance_1_2345": "firefox",
+		"spotify": "spotify",
+	}
+	for name, want := range tests {
+		if got := playerKey(name); got != want {
+			t.Errorf("playerKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestOffsetsFile(t *testing.T) {
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = t.TempDir()
+
+	info := &PlayerInfo{ID: "/com/spotify/track/abc"}
+	if o, err := LoadOffsets(); err != nil || o.Total("spotify", info) != 0 {
+		t.Fatalf("LoadOffsets() without a file = %+v, %v", o, err)
+	}
+
+	saved := Offsets{
+		Global:  -20,
+		Players: map[string]int64{"spotify": 150},
+		Tracks:  map[string]int64{LyricsKey(info): -300},
+	}
+	if err := SaveOffsets(nil, saved); err != nil {
+		t.Fatal(err)
+	}
+	o, err := LoadOffsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := o.Total("org.mpris.MediaPlayer2.spotify", info); got != -170*time.Millisecond {
+		t.Errorf("Total() = %s, want -170ms", got)
+	}
+	if got := o.Total("org.mpris.MediaPlayer2.vlc", nil); got != -20*time.Millisecond {
+		t.Errorf("Total() of another player = %s, want -20ms", got)
+	}
+}
+
+func TestUpdateOffsets(t *testing.T) {
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = t.TempDir()
+
+	// Every change is applied to the offsets saved by the others
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := UpdateOffsets(nil, func(o *Offsets) error {
+				o.Global += 10
+				return nil
+			})
+			if err != nil {
+				t.Errorf("UpdateOffsets() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	o, err := LoadOffsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Global != 200 {
+		t.Errorf("Global = %d after 20 changes of 10ms, want 200", o.Global)
+	}
+}
+
+func TestRunOffsetOptions(t *testing.T) {
+	err := RunOffset([]string{"-100ms", "--verbose"})
+	if err == nil || !strings.Contains(err.Error(), "options go before the command") {
+		t.Errorf("RunOffset() with an option after the command error = %v", err)
+	}
+}
@@ -1,47 +1,302 @@
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
-// ParseLyrics parses a string containing time-synchronized lyrics in the format [MM:SS.ss]Lyric text
-// and returns a slice of LyricLine structs. Each line in the input should follow the format
-// "[timestamp]lyric text", where timestamp is in a format parseable by ParseTimestamp.
+// ParseLyrics parses a string containing time-synchronized lyrics in the LRC format and returns
+// a sorted slice of LyricLine structs along with the header metadata. Each line in the input
+// should follow the format "[timestamp]lyric text", where timestamp is in a format parseable by
+// ParseTimestamp. A line may start with several timestamps ("[00:12.00][01:40.00]chorus"), in
+// which case it is repeated for each of them. Header tags like "[ar:Artist]" are returned as
+// Metadata and the "[offset:+250]" tag (milliseconds) is applied to every timestamp.
 // Empty lines and malformed lines are skipped.
-func ParseLyrics(file string) ([]LyricLine, error) {
+func ParseLyrics(file string) ([]LyricLine, Metadata, error) {
+	return parseLRC(file, nil)
+}
+
+// ParseLyricsStrict parses lyrics like ParseLyrics, but fails with the first
+// problem LintLyrics would report instead of skipping it.
+func ParseLyricsStrict(file string) ([]LyricLine, Metadata, error) {
+	var problems []Diagnostic
+	lyrics, metadata, err := parseLRC(file, func(d Diagnostic) {
+		problems = append(problems, d)
+	})
+
+	if len(problems) != 0 {
+		return nil, metadata, problems[0]
+	}
+	return lyrics, metadata, err
+}
+
+// isTimestamp reports whether tag only contains characters of a timestamp
+func isTimestamp(tag string) bool {
+	return strings.Trim(tag, "0123456789:. ") == ""
+}
+
+// isWordTimestamp reports whether the tag of a word is a timestamp with
+// minutes, unlike text such as "<3>" or "<Inf>"
+func isWordTimestamp(tag string) bool {
+	return isTimestamp(tag) && strings.Contains(tag, ":")
+}
+
+// parseLRC parses a LRC file. Every skipped or suspicious part of the file is
+// passed to report when it isn't nil.
+func parseLRC(file string, report func(Diagnostic)) ([]LyricLine, Metadata, error) {
 	var lyrics []LyricLine
-	for line := range strings.SplitSeq(file, "\n") {
-		if line == "" {
+	metadata := make(Metadata)
+
+	lint := newLinter(report)
+
+	// Indexes of lyrics added for the previous line, [bg:] lines belong to them
+	var previous []int
+
+	for n, line := range strings.Split(file, "\n") {
+		lint.startLine(n+1, line)
+
+		rest := strings.TrimSpace(line)
+		if rest == "" {
 			continue
 		}
 
-		parts := strings.SplitN(line, "]", 2)
-		if len(parts) != 2 {
-			continue
+		var timestamps []time.Duration
+		malformed, tagged := false, false
+		for strings.HasPrefix(rest, "[") {
+			column := lint.column(rest)
+
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				lint.report(column, "unclosed tag")
+				malformed = true
+				break
+			}
+			tag := rest[1:end]
+
+			if timestamp, err := ParseTimestamp(tag); err == nil && isTimestamp(tag) {
+				lint.timestamp(column, tag, timestamp)
+				timestamps = append(timestamps, timestamp)
+				rest = rest[end+1:]
+				continue
+			}
+
+			if first := strings.TrimSpace(tag); first != "" && strings.ContainsRune("0123456789-+.", rune(first[0])) {
+				lint.malformedTimestamp(column, tag)
+				malformed = true
+				break
+			}
+
+			key, value, ok := parseTag(tag)
+			if !ok || len(timestamps) != 0 {
+				break
+			}
+			lint.tag(column, key, value)
+			tagged = true
+			rest = rest[end+1:]
+
+			if key != "bg" {
+				metadata[key] = value
+				continue
+			}
+
+			if len(previous) == 0 {
+				lint.report(column, "background vocals without a lyrics line")
+				continue
+			}
+
+			first := lyrics[previous[0]].Timestamp
+			text, words := ParseWords(value, first)
+			for _, i := range previous {
+				d := lyrics[i].Timestamp - first
+				bg := &LyricLine{Timestamp: lyrics[i].Timestamp, Text: text, Words: shiftWords(words, d)}
+				if len(bg.Words) != 0 {
+					bg.Timestamp = bg.Words[0].Timestamp
+				}
+				lyrics[i].Background = bg
+			}
 		}
 
-		timestampStr := strings.TrimPrefix(parts[0], "[")
-		lyricLine := strings.TrimSpace(parts[1])
+		if malformed {
+			continue
+		}
 
-		timestamp, err := ParseTimestamp(timestampStr)
-		if err != nil {
+		if len(timestamps) == 0 {
+			if text := strings.TrimSpace(rest); text != "" {
+				column := lint.column(strings.TrimLeftFunc(rest, unicode.IsSpace))
+				if tagged {
+					lint.report(column, "unexpected text after tag: %q", text)
+				} else {
+					lint.report(column, "line has no timestamp")
+				}
+			}
 			continue
 		}
 
-		lyric := LyricLine{Timestamp: timestamp, Text: lyricLine}
-		lyrics = append(lyrics, lyric)
+		lint.words(rest)
+		lint.line()
+
+		voice, rest := cutVoice(strings.TrimSpace(rest))
+		lyricLine, words := ParseWords(rest, timestamps[0])
+
+		previous = previous[:0]
+		for _, timestamp := range timestamps {
+			lyric := LyricLine{Timestamp: timestamp, Text: lyricLine, Voice: voice}
+			// Word timings of repeated lines are relative to the first timestamp
+			lyric.Words = shiftWords(words, timestamp-timestamps[0])
+			previous = append(previous, len(lyrics))
+			lyrics = append(lyrics, lyric)
+		}
 	}
 
+	lint.finish(metadata)
+
 	if len(lyrics) == 0 {
-		return lyrics, errors.New("Lyric lines are 0")
+		return lyrics, metadata, errors.New("Lyric lines are 0")
+	}
+
+	if offset := metadata.Offset(); offset != 0 {
+		for i := range lyrics {
+			// A positive offset makes lyrics appear sooner
+			lyrics[i].Timestamp = max(lyrics[i].Timestamp-offset, 0)
+			lyrics[i].Words = shiftWords(lyrics[i].Words, -offset)
+			if bg := lyrics[i].Background; bg != nil {
+				lyrics[i].Background = &LyricLine{
+					Timestamp: max(bg.Timestamp-offset, 0),
+					Text:      bg.Text,
+					Words:     shiftWords(bg.Words, -offset),
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(lyrics, func(a, b LyricLine) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+
+	return MergeTranslations(lyrics), metadata, nil
+}
+
+// MergeTranslations merges lines of sorted lyrics sharing a timestamp. The
+// first line is kept and the text of the following ones becomes its
+// Translation. Empty lines next to a line with text are dropped.
+func MergeTranslations(lyrics []LyricLine) []LyricLine {
+	merged := make([]LyricLine, 0, len(lyrics))
+	for _, line := range lyrics {
+		if len(merged) == 0 || merged[len(merged)-1].Timestamp != line.Timestamp {
+			merged = append(merged, line)
+			continue
+		}
+
+		last := &merged[len(merged)-1]
+		switch {
+		case line.Text == "":
+		case last.Text == "":
+			*last = line
+		case last.Translation == "":
+			last.Translation = line.Text
+		default:
+			last.Translation += " / " + line.Text
+		}
+	}
+	return merged
+}
+
+// ParseWords parses the word timestamps of an enhanced LRC line like
+// "<00:12.00>Hello <00:12.50>world <00:13.00>" and returns the plain text and
+// the words. Text before the first word timestamp starts at lineTimestamp. A
+// trailing timestamp marks the end of the last word and is kept as an empty
+// word. Words is nil when the line doesn't have word timestamps.
+func ParseWords(text string, lineTimestamp time.Duration) (string, []LyricWord) {
+	var words []LyricWord
+	var plain strings.Builder
+
+	current := LyricWord{Timestamp: lineTimestamp}
+	found := false
+	rest := text
+	for {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		tag := rest[start+1 : end]
+		timestamp, err := ParseTimestamp(tag)
+		if err != nil || !isWordTimestamp(tag) {
+			// Not a word timestamp, e.g. "<3" or "<3>"
+			current.Text += rest[:end+1]
+			rest = rest[end+1:]
+			continue
+		}
+
+		current.Text += rest[:start]
+		if current.Text != "" || found {
+			words = append(words, current)
+		}
+		current = LyricWord{Timestamp: timestamp}
+		found = true
+		rest = rest[end+1:]
+	}
+
+	if !found {
+		return strings.TrimSpace(text), nil
+	}
+
+	current.Text += rest
+	words = append(words, current)
+
+	for _, word := range words {
+		plain.WriteString(word.Text)
+	}
+
+	return strings.TrimSpace(plain.String()), words
+}
+
+func shiftWords(words []LyricWord, d time.Duration) []LyricWord {
+	if words == nil {
+		return nil
+	}
+	shifted := make([]LyricWord, len(words))
+	for i, word := range words {
+		shifted[i] = LyricWord{Timestamp: max(word.Timestamp+d, 0), Text: word.Text}
+	}
+	return shifted
+}
+
+// cutVoice splits the duet voice prefix of an enhanced LRC line ("v1: text")
+func cutVoice(text string) (string, string) {
+	voice, rest, ok := strings.Cut(text, ":")
+	if !ok || len(voice) < 2 || voice[0] != 'v' || strings.Trim(voice[1:], "0123456789") != "" {
+		return "", text
+	}
+	return voice, strings.TrimSpace(rest)
+}
+
+// parseTag parses a LRC header tag like "ar:Artist" into its key and value
+func parseTag(tag string) (string, string, bool) {
+	key, value, ok := strings.Cut(tag, ":")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !ok || key == "" {
+		return "", "", false
+	}
+
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '#' {
+			return "", "", false
+		}
 	}
 
-	return lyrics, nil
+	return key, strings.TrimSpace(value), true
 }
 
 // ParseTimestamp converts a timestamp string (in "HH:MM:SS", "MM:SS" or "SS" format)
@@ -125,10 +125,11 @@ func TestParseTimestamp(t *testing.T) {
 
 func TestParseLyrics(t *testing.T) {
 	tests := []struct {
-		name    string
-		file    string
-		want    []LyricLine
-		wantErr bool
+		name     string
+		file     string
+		want     []LyricLine
+		wantMeta Metadata
+		wantErr  bool
 	}{
 		{
 			name:    "Empty file",
@@ -183,11 +184,187 @@ func TestParseLyrics(t *testing.T) {
 				{Timestamp: 5 * time.Second, Text: "Text with spaces"},
 			},
 		},
+		{
+			name: "Multiple timestamps per line",
+			file: "[00:12.00][01:40.00]Chorus\n[00:30.00]Verse",
+			want: []LyricLine{
+				{Timestamp: 12 * time.Second, Text: "Chorus"},
+				{Timestamp: 30 * time.Second, Text: "Verse"},
+				{Timestamp: 1*time.Minute + 40*time.Second, Text: "Chorus"},
+			},
+		},
+		{
+			name: "Sort unordered lines",
+			file: "[00:10.00]Third\n[00:05.00]First\n[00:07.00]Second",
+			want: []LyricLine{
+				{Timestamp: 5 * time.Second, Text: "First"},
+				{Timestamp: 7 * time.Second, Text: "Second"},
+				{Timestamp: 10 * time.Second, Text: "Third"},
+			},
+		},
+		{
+			name: "Merge translations",
+			file: "[00:05.00]君の名は\n[00:05.00]Your name\n[00:07.00]\n[00:07.00]Next\n[00:09.00]One\n[00:09.00]Two\n[00:09.00]Three",
+			want: []LyricLine{
+				{Timestamp: 5 * time.Second, Text: "君の名は", Translation: "Your name"},
+				{Timestamp: 7 * time.Second, Text: "Next"},
+				{Timestamp: 9 * time.Second, Text: "One", Translation: "Two / Three"},
+			},
+		},
+		{
+			name: "Header metadata",
+			file: "[ar:Queen]\n[ti: Bohemian Rhapsody ]\n[al:A Night at the Opera]\n[length:05:55]\n[00:01.00]Is this the real life?",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "Is this the real life?"},
+			},
+			wantMeta: Metadata{
+				"ar":     "Queen",
+				"ti":     "Bohemian Rhapsody",
+				"al":     "A Night at the Opera",
+				"length": "05:55",
+			},
+		},
+		{
+			name: "Positive offset",
+			file: "[offset:+250]\n[00:01.00]First\n[00:00.10]Zero",
+			want: []LyricLine{
+				{Timestamp: 0, Text: "Zero"},
+				{Timestamp: 750 * time.Millisecond, Text: "First"},
+			},
+			wantMeta: Metadata{"offset": "+250"},
+		},
+		{
+			name: "Negative offset",
+			file: "[offset:-500]\n[00:01.00]First",
+			want: []LyricLine{
+				{Timestamp: 1*time.Second + 500*time.Millisecond, Text: "First"},
+			},
+			wantMeta: Metadata{"offset": "-500"},
+		},
+		{
+			name: "Tags in the middle of a line are text",
+			file: "[00:01.00]Hello [ar:World]",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "Hello [ar:World]"},
+			},
+		},
+		{
+			name: "Enhanced word timestamps",
+			file: "[00:12.00]<00:12.00>Hello <00:12.50>world <00:13.00>",
+			want: []LyricLine{
+				{
+					Timestamp: 12 * time.Second,
+					Text:      "Hello world",
+					Words: []LyricWord{
+						{Timestamp: 12 * time.Second, Text: "Hello "},
+						{Timestamp: 12*time.Second + 500*time.Millisecond, Text: "world "},
+						{Timestamp: 13 * time.Second, Text: ""},
+					},
+				},
+			},
+		},
+		{
+			name: "Enhanced words on repeated line with offset",
+			file: "[offset:1000]\n[00:12.00][00:42.00]Hey <00:12.50>you",
+			want: []LyricLine{
+				{
+					Timestamp: 11 * time.Second,
+					Text:      "Hey you",
+					Words: []LyricWord{
+						{Timestamp: 11 * time.Second, Text: "Hey "},
+						{Timestamp: 11*time.Second + 500*time.Millisecond, Text: "you"},
+					},
+				},
+				{
+					Timestamp: 41 * time.Second,
+					Text:      "Hey you",
+					Words: []LyricWord{
+						{Timestamp: 41 * time.Second, Text: "Hey "},
+						{Timestamp: 41*time.Second + 500*time.Millisecond, Text: "you"},
+					},
+				},
+			},
+			wantMeta: Metadata{"offset": "1000"},
+		},
+		{
+			name: "Angle brackets without timestamp are text",
+			file: "[00:01.00]I <3 you <b>",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "I <3 you <b>"},
+			},
+		},
+		{
+			name: "Numbers in angle brackets are text",
+			file: "[00:01.00]I <3> you <1e3> <Inf>\n[00:02.00]<00:02.00>Love <3> <00:02.50>you",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "I <3> you <1e3> <Inf>"},
+				{
+					Timestamp: 2 * time.Second,
+					Text:      "Love <3> you",
+					Words: []LyricWord{
+						{Timestamp: 2 * time.Second, Text: "Love <3> "},
+						{Timestamp: 2500 * time.Millisecond, Text: "you"},
+					},
+				},
+			},
+		},
+		{
+			name: "Duet voices",
+			file: "[00:01.00]v1: Hello\n[00:02.00]v2:World\n[00:03.00]vintage: not a voice",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "Hello", Voice: "v1"},
+				{Timestamp: 2 * time.Second, Text: "World", Voice: "v2"},
+				{Timestamp: 3 * time.Second, Text: "vintage: not a voice"},
+			},
+		},
+		{
+			name: "Background vocals of repeated line with offset",
+			file: "[offset:500]\n[00:10.00][00:40.00]Main\n[bg:<00:11.00>(oh <00:11.50>yeah)]",
+			want: []LyricLine{
+				{
+					Timestamp: 9500 * time.Millisecond,
+					Text:      "Main",
+					Background: &LyricLine{
+						Timestamp: 10500 * time.Millisecond,
+						Text:      "(oh yeah)",
+						Words: []LyricWord{
+							{Timestamp: 10500 * time.Millisecond, Text: "(oh "},
+							{Timestamp: 11 * time.Second, Text: "yeah)"},
+						},
+					},
+				},
+				{
+					Timestamp: 39500 * time.Millisecond,
+					Text:      "Main",
+					Background: &LyricLine{
+						Timestamp: 40500 * time.Millisecond,
+						Text:      "(oh yeah)",
+						Words: []LyricWord{
+							{Timestamp: 40500 * time.Millisecond, Text: "(oh "},
+							{Timestamp: 41 * time.Second, Text: "yeah)"},
+						},
+					},
+				},
+			},
+			wantMeta: Metadata{"offset": "500"},
+		},
+		{
+			name: "Background vocals without a line are skipped",
+			file: "[bg:oh]\n[00:01.00]Hello",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "Hello"},
+			},
+		},
+		{
+			name:    "Metadata only",
+			file:    "[ar:Queen]\n[ti:Bohemian Rhapsody]",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, gotErr := ParseLyrics(tt.file)
+			got, gotMeta, gotErr := ParseLyrics(tt.file)
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("ParseLyrics() failed: %v", gotErr)
@@ -201,6 +378,13 @@ func TestParseLyrics(t *testing.T) {
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ParseLyrics() = %v, want %v", got, tt.want)
 			}
+
+			if tt.wantMeta == nil {
+				tt.wantMeta = Metadata{}
+			}
+			if !reflect.DeepEqual(gotMeta, tt.wantMeta) {
+				t.Errorf("ParseLyrics() metadata = %v, want %v", gotMeta, tt.wantMeta)
+			}
 		})
 	}
 }
@@ -3,11 +3,17 @@ package main
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Nadim147c/go-mpris"
+	"github.com/godbus/dbus/v5"
 )
 
+const mprisPrefix = "org.mpris.MediaPlayer2."
+
 // StringToMD5 converts a string to its MD5 hash
 func StringToMD5(s string) string {
 	hash := md5.Sum([]byte(s))
@@ -31,6 +37,23 @@ func GetSpotifyInfo(player *mpris.Player) (*PlayerInfo, error) {
 		return nil, err
 	}
 
+	return newPlayerInfo(meta, status, position)
+}
+
+// metadataLength returns mpris:length of the metadata, which players send as
+// int64 or uint64 microseconds
+func metadataLength(meta map[string]dbus.Variant) time.Duration {
+	switch length := meta["mpris:length"].Value().(type) {
+	case int64:
+		return time.Duration(length) * time.Microsecond
+	case uint64:
+		return time.Duration(length) * time.Microsecond
+	}
+	return 0
+}
+
+// newPlayerInfo creates the PlayerInfo of mpris metadata
+func newPlayerInfo(meta map[string]dbus.Variant, status mpris.PlaybackStatus, position time.Duration) (*PlayerInfo, error) {
 	artistList, ok := meta["xesam:artist"].Value().([]string)
 	if !ok || len(artistList) == 0 {
 		return nil, fmt.Errorf("missing artist information")
@@ -48,10 +71,6 @@ func GetSpotifyInfo(player *mpris.Player) (*PlayerInfo, error) {
 	}
 
 	album, _ := meta["xesam:album"].Value().(string)
-	length, err := player.GetLength()
-	if err != nil {
-		return nil, err
-	}
 
 	return &PlayerInfo{
 		ID:       id,
@@ -60,6 +79,41 @@ func GetSpotifyInfo(player *mpris.Player) (*PlayerInfo, error) {
 		Album:    album,
 		Status:   status,
 		Position: position,
-		Length:   length,
+		Length:   metadataLength(meta),
 	}, nil
 }
+
+// FindPlayer returns the last mpris player registered on the session bus
+func FindPlayer(conn *dbus.Conn) (*mpris.Player, error) {
+	// Call ListNames on org.freedesktop.DBus
+	var names []string
+	err := conn.Object("org.freedesktop.DBus", "/org/freedesktop/DBus").
+		Call("org.freedesktop.DBus.ListNames", 0).
+		Store(&names)
+	if err != nil {
+		return nil, err
+	}
+
+	playerName, err := lastPlayerName(names)
+	if err != nil {
+		return nil, err
+	}
+	return mpris.New(conn, playerName), nil
+}
+
+// ErrNoPlayer is returned by FindPlayer when no mpris player is on the bus
+var ErrNoPlayer = errors.New("no mpris player found")
+
+// lastPlayerName returns the last mpris player of the bus names
+func lastPlayerName(names []string) (string, error) {
+	var playerName string
+	for _, name := range names {
+		if strings.HasPrefix(name, mprisPrefix) {
+			playerName = name
+		}
+	}
+	if playerName == "" {
+		return "", ErrNoPlayer
+	}
+	return playerName, nil
+}
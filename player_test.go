@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLastPlayerName(t *testing.T) {
+	name, err := lastPlayerName([]string{"org.freedesktop.DBus", "org.mpris.MediaPlayer2.spotify", ":1.5", "org.mpris.MediaPlayer2.mpv"})
+	if err != nil || name != "org.mpris.MediaPlayer2.mpv" {
+		t.Errorf("lastPlayerName() = %q, %v, want the last player", name, err)
+	}
+
+	// A bus without players must not give a player with an empty name
+	if name, err := lastPlayerName([]string{"org.freedesktop.DBus", ":1.5"}); !errors.Is(err, ErrNoPlayer) {
+		t.Errorf("lastPlayerName() without players = %q, %v, want ErrNoPlayer", name, err)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PrefetchResult is the outcome of looking up a single track
+type PrefetchResult string
+
+const (
+	PrefetchCached  PrefetchResult = "cached"
+	PrefetchFetched PrefetchResult = "fetched"
+	PrefetchMissing PrefetchResult = "missing"
+	PrefetchFailed  PrefetchResult = "failed"
+)
+
+// trackFromName guesses artist and title from a "Artist - Title" string
+func trackFromName(name string) (*PlayerInfo, bool) {
+	artist, title, ok := strings.Cut(name, " - ")
+	artist, title = strings.TrimSpace(artist), strings.TrimSpace(title)
+	if !ok || artist == "" || title == "" {
+		return nil, false
+	}
+	return &PlayerInfo{ID: StringToMD5(artist + title), Artist: artist, Title: title}, true
+}
+
+// trackFromFile reads the tags of an audio file and falls back to the file name
+func trackFromFile(path string) (*PlayerInfo, error) {
+	info, err := ReadAudioTags(path)
+	if err == nil {
+		return info, nil
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if info, ok := trackFromName(name); ok {
+		return info, nil
+	}
+
+	return nil, err
+}
+
+// ReadM3U reads tracks from a M3U/M3U8 playlist. #EXTINF information is used
+// when present, otherwise the referenced file is read for tags.
+func ReadM3U(r io.Reader, baseDir string) ([]*PlayerInfo, error) {
+	var tracks []*PlayerInfo
+	var extinf *PlayerInfo
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" {
+			continue
+		}
+
+		if after, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+			extinf = nil
+			duration, name, _ := strings.Cut(after, ",")
+			if info, ok := trackFromName(name); ok {
+				// Duration may be followed by attributes: #EXTINF:123 tvg-id="",Name
+				duration, _, _ = strings.Cut(duration, " ")
+				if secs, err := ParseTimestamp(duration); err == nil {
+					info.Length = secs
+				}
+				extinf = info
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if extinf != nil {
+			tracks = append(tracks, extinf)
+			extinf = nil
+			continue
+		}
+
+		path := line
+		if !filepath.IsAbs(path) && !strings.Contains(path, "://") {
+			path = filepath.Join(baseDir, path)
+		}
+
+		info, err := trackFromFile(path)
+		if err != nil {
+			slog.Warn("Skipping playlist entry", "entry", line, "error", err)
+			continue
+		}
+		tracks = append(tracks, info)
+	}
+
+	return tracks, scanner.Err()
+}
+
+// ReadTrackCSV reads tracks from a CSV file with artist, title, album and
+// duration columns. Album and duration are optional and a header row is
+// skipped.
+func ReadTrackCSV(r io.Reader) ([]*PlayerInfo, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var tracks []*PlayerInfo
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(record) < 2 {
+			continue
+		}
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "artist") {
+			continue
+		}
+
+		info := &PlayerInfo{
+			Artist: strings.TrimSpace(record[0]),
+			Title:  strings.TrimSpace(record[1]),
+		}
+		if info.Artist == "" || info.Title == "" {
+			continue
+		}
+		if len(record) > 2 {
+			info.Album = strings.TrimSpace(record[2])
+		}
+		if len(record) > 3 && strings.TrimSpace(record[3]) != "" {
+			if length, err := ParseTimestamp(record[3]); err == nil {
+				info.Length = length
+			}
+		}
+
+		info.ID = StringToMD5(info.Artist + info.Title)
+		tracks = append(tracks, info)
+	}
+
+	return tracks, nil
+}
+
+// ReadAudioDir recursively reads tags of every audio file in dir
+func ReadAudioDir(dir string) ([]*PlayerInfo, error) {
+	var tracks []*PlayerInfo
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !IsAudioFile(path) {
+			return nil
+		}
+
+		info, err := trackFromFile(path)
+		if err != nil {
+			slog.Warn("Skipping audio file", "path", path, "error", err)
+			return nil
+		}
+		tracks = append(tracks, info)
+		return nil
+	})
+	return tracks, err
+}
+
+// ReadTracks reads tracks from a playlist, csv file or directory
+func ReadTracks(path string) ([]*PlayerInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		return ReadAudioDir(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8":
+		return ReadM3U(file, filepath.Dir(path))
+	case ".csv":
+		return ReadTrackCSV(file)
+	default:
+		return nil, fmt.Errorf("unsupported track list: %s", path)
+	}
+}
+
+// prefetchProgressFile returns the file where finished tracks of a prefetch
+// run are recorded, so an interrupted run can be resumed.
+func prefetchProgressFile(source string) string {
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+	return filepath.Join(CacheDir, "prefetch-"+StringToMD5(source)+".progress")
+}
+
+func loadPrefetchProgress(path string) map[string]bool {
+	done := make(map[string]bool)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return done
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		done[strings.TrimSpace(scanner.Text())] = true
+	}
+	return done
+}
+
+// prefetchTrack runs a single track through GetLyrics
+func prefetchTrack(info *PlayerInfo) (PrefetchResult, error) {
+	if LyricsCached(info) {
+		return PrefetchCached, nil
+	}
+
+	_, err := GetLyrics(info)
+	switch {
+	case err == nil:
+		return PrefetchFetched, nil
+	case errors.Is(err, ErrLyricsNotFound):
+		return PrefetchMissing, nil
+	default:
+		return PrefetchFailed, err
+	}
+}
+
+// Prefetch warms the lyrics cache for every track in source. Finished tracks
+// are recorded in a progress file and skipped when the command is rerun.
+func Prefetch(source string) error {
+	tracks, err := ReadTracks(source)
+	if err != nil {
+		return fmt.Errorf("failed to read tracks: %w", err)
+	}
+	if len(tracks) == 0 {
+		return fmt.Errorf("no tracks found in %s", source)
+	}
+
+	progressPath := prefetchProgressFile(source)
+	done := loadPrefetchProgress(progressPath)
+	seenTracks := make(map[string]bool)
+
+	progress, err := os.OpenFile(progressPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open progress file: %w", err)
+	}
+	defer progress.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The same track may appear more than once in a playlist
+	tracks = slices.DeleteFunc(tracks, func(track *PlayerInfo) bool {
+		seen := seenTracks[track.ID]
+		seenTracks[track.ID] = true
+		return seen
+	})
+
+	jobs := make(chan *PlayerInfo)
+	go func() {
+		defer close(jobs)
+		for _, track := range tracks {
+			if done[track.ID] {
+				continue
+			}
+			select {
+			case jobs <- track:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	counts := make(map[PrefetchResult]int)
+	finished := 0
+	for _, track := range tracks {
+		if done[track.ID] {
+			finished++
+		}
+	}
+	skipped := finished
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for range max(PrefetchJobs, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for track := range jobs {
+				result, err := prefetchTrack(track)
+
+				mu.Lock()
+				counts[result]++
+				finished++
+				fmt.Fprintf(os.Stderr, "[%d/%d] %s - %s: %s\n", finished, len(tracks), track.Artist, track.Title, result)
+				if err != nil {
+					slog.Debug("Prefetch failed", "artist", track.Artist, "title", track.Title, "error", err)
+				} else {
+					fmt.Fprintln(progress, track.ID)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	fmt.Fprintf(os.Stderr, "\nPrefetched %d tracks in %s\n", finished-skipped, time.Since(start).Round(time.Second))
+	fmt.Fprintf(os.Stderr, "  fetched: %d\n  cached:  %d\n  missing: %d\n  failed:  %d\n  resumed: %d\n",
+		counts[PrefetchFetched], counts[PrefetchCached], counts[PrefetchMissing], counts[PrefetchFailed], skipped)
+
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted, run the same command again to resume.")
+		return nil
+	}
+
+	if counts[PrefetchFailed] > 0 {
+		fmt.Fprintln(os.Stderr, "Some tracks failed, run the same command again to retry them.")
+		return nil
+	}
+
+	progress.Close()
+	return os.Remove(progressPath)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTrackCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want []PlayerInfo
+	}{
+		{
+			name: "Header and all columns",
+			file: "artist,title,album,duration\nQueen,Bohemian Rhapsody,A Night at the Opera,354\n",
+			want: []PlayerInfo{
+				{Artist: "Queen", Title: "Bohemian Rhapsody", Album: "A Night at the Opera", Length: 354 * time.Second},
+			},
+		},
+		{
+			name: "Without header and optional columns",
+			file: "Queen,Love of My Life\nABBA, Waterloo ,,2:42\n",
+			want: []PlayerInfo{
+				{Artist: "Queen", Title: "Love of My Life"},
+				{Artist: "ABBA", Title: "Waterloo", Length: 2*time.Minute + 42*time.Second},
+			},
+		},
+		{
+			name: "Skip incomplete rows",
+			file: "Queen\n,Title\nQueen,\"Don't Stop Me Now\"\n",
+			want: []PlayerInfo{
+				{Artist: "Queen", Title: "Don't Stop Me Now"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadTrackCSV(strings.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("ReadTrackCSV() failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ReadTrackCSV() returned %d tracks, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				want.ID = StringToMD5(want.Artist + want.Title)
+				if *got[i] != want {
+					t.Errorf("ReadTrackCSV()[%d] = %+v, want %+v", i, *got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadM3U(t *testing.T) {
+	playlist := strings.Join([]string{
+		"#EXTM3U",
+		"#EXTINF:354,Queen - Bohemian Rhapsody",
+		"music/queen/bohemian.mp3",
+		"#EXTINF:-1 tvg-id=\"x\",ABBA - Waterloo",
+		"https://example.com/waterloo.mp3",
+		"",
+		"missing/Nobody - Nothing.mp3",
+	}, "\n")
+
+	got, err := ReadM3U(strings.NewReader(playlist), t.TempDir())
+	if err != nil {
+		t.Fatalf("ReadM3U() failed: %v", err)
+	}
+
+	want := []PlayerInfo{
+		{Artist: "Queen", Title: "Bohemian Rhapsody", Length: 354 * time.Second},
+		{Artist: "ABBA", Title: "Waterloo"},
+		{Artist: "Nobody", Title: "Nothing"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadM3U() returned %d tracks, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		w.ID = StringToMD5(w.Artist + w.Title)
+		if *got[i] != w {
+			t.Errorf("ReadM3U()[%d] = %+v, want %+v", i, *got[i], w)
+		}
+	}
+}
+
+func TestPrefetchedLyricsKey(t *testing.T) {
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = t.TempDir()
+
+	tracks, err := ReadTrackCSV(strings.NewReader("Queen,Love of My Life\n"))
+	if err != nil {
+		t.Fatalf("ReadTrackCSV() failed: %v", err)
+	}
+
+	// Prefetch stores the lyrics of a track at its LyricsKey
+	want := Lyrics{{Timestamp: time.Second, Text: "Love of my life"}}
+	if err := SaveCache(want, nil, filepath.Join(CacheDir, LyricsKey(tracks[0])+".csv")); err != nil {
+		t.Fatalf("SaveCache() failed: %v", err)
+	}
+
+	info := &PlayerInfo{ID: "/com/spotify/track/7h4mTp3dV3XCk4GdovNmqU", Artist: "queen", Title: "Love of  My Life"}
+	defer LyricStore.Delete(LyricsKey(info))
+	if !LyricsCached(info) {
+		t.Fatalf("LyricsCached() = false, want true")
+	}
+	got, err := GetLyrics(info)
+	if err != nil {
+		t.Fatalf("GetLyrics() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Text != want[0].Text {
+		t.Errorf("GetLyrics() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLegacyLyricsCache(t *testing.T) {
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = t.TempDir()
+
+	// Older versions keyed the cache by the base name of the track id
+	info := &PlayerInfo{ID: "/com/spotify/track/3z8h0TU7ReDPLIbEnYhWZb", Artist: "Queen", Title: "Bohemian Rhapsody"}
+	defer LyricStore.Delete(LyricsKey(info))
+	want := Lyrics{{Timestamp: time.Second, Text: "Is this the real life?"}}
+	legacy := filepath.Join(CacheDir, "3z8h0TU7ReDPLIbEnYhWZb.csv")
+	if err := SaveCache(want, nil, legacy); err != nil {
+		t.Fatalf("SaveCache() failed: %v", err)
+	}
+
+	got, err := GetLyrics(info)
+	if err != nil {
+		t.Fatalf("GetLyrics() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Text != want[0].Text {
+		t.Errorf("GetLyrics() = %+v, want %+v", got, want)
+	}
+
+	if _, err := os.Stat(legacy); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("legacy cache file is kept, error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(CacheDir, LyricsKey(info)+".csv")); err != nil {
+		t.Errorf("cache file of the new key is missing: %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	LrclibChallengeEndpoint = "https://lrclib.net/api/request-challenge"
+	LrclibPublishEndpoint   = "https://lrclib.net/api/publish"
+)
+
+// LrcLibChallenge is the proof-of-work challenge sent from LrcLib api
+type LrcLibChallenge struct {
+	Prefix string `json:"prefix"`
+	Target string `json:"target"`
+}
+
+// LrcLibPublish is the request body of the LrcLib publish api
+type LrcLibPublish struct {
+	TrackName    string  `json:"trackName"`
+	ArtistName   string  `json:"artistName"`
+	AlbumName    string  `json:"albumName"`
+	Duration     float64 `json:"duration"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"`
+}
+
+// LrcLibError is the error response of LrcLib api
+type LrcLibError struct {
+	Code    int    `json:"statusCode"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// SolveChallenge finds a nonce so that sha256(prefix + nonce) is lower than or
+// equal to target when compared byte by byte.
+func SolveChallenge(prefix, target string) (string, error) {
+	targetBytes, err := hex.DecodeString(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid challenge target: %w", err)
+	}
+	if len(targetBytes) != sha256.Size {
+		return "", fmt.Errorf("invalid challenge target length: %d", len(targetBytes))
+	}
+
+	for nonce := uint64(0); ; nonce++ {
+		n := strconv.FormatUint(nonce, 10)
+		hash := sha256.Sum256([]byte(prefix + n))
+		if bytes.Compare(hash[:], targetBytes) <= 0 {
+			return n, nil
+		}
+	}
+}
+
+func requestChallenge() (*LrcLibChallenge, error) {
+	waitRateLimit()
+
+	req, err := http.NewRequest(http.MethodPost, LrclibChallengeEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", Version)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
+	}
+
+	var challenge LrcLibChallenge
+	if err := json.NewDecoder(resp.Body).Decode(&challenge); err != nil {
+		return nil, fmt.Errorf("failed to read challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// PublishLyrics submits lyrics to LrcLib
+func PublishLyrics(payload *LrcLibPublish) error {
+	challenge, err := requestChallenge()
+	if err != nil {
+		return fmt.Errorf("failed to request challenge: %w", err)
+	}
+
+	slog.Info("Solving publish challenge", "target", challenge.Target)
+	nonce, err := SolveChallenge(challenge.Prefix, challenge.Target)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	waitRateLimit()
+	req, err := http.NewRequest(http.MethodPost, LrclibPublishEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", Version)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Publish-Token", challenge.Prefix+":"+nonce)
+
+	slog.Info("Publishing lyrics to Lrclib", "track", payload.TrackName, "artist", payload.ArtistName)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var lrcErr LrcLibError
+		msg, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(msg, &lrcErr) == nil && lrcErr.Message != "" {
+			return fmt.Errorf("LrcLib rejected lyrics: %s", lrcErr.Message)
+		}
+		return fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// publishTrack returns the track which the lyrics are published for. The
+// track is described by the tags of the lyrics and an "Artist - Title" query.
+// Missing fields are taken from the playing track, but only when it is the
+// same track. An empty query refers to the playing track.
+func publishTrack(query string, metadata Metadata, playing *PlayerInfo) (*PlayerInfo, error) {
+	track := &PlayerInfo{}
+	if query == "" && playing != nil {
+		*track = PlayerInfo{Artist: playing.Artist, Title: playing.Title, Album: playing.Album, Length: playing.Length}
+	}
+	// The name of a lyrics file isn't a query, its tags describe the track
+	if named, ok := trackFromName(query); ok && !isFile(query) {
+		track.Artist, track.Title = named.Artist, named.Title
+	}
+
+	if track.Title == "" {
+		track.Title = metadata["ti"]
+	}
+	if track.Artist == "" {
+		track.Artist = metadata["ar"]
+	}
+	if track.Album == "" {
+		track.Album = metadata["al"]
+	}
+	if track.Length == 0 && metadata["length"] != "" {
+		if length, err := ParseTimestamp(metadata["length"]); err == nil {
+			track.Length = length
+		}
+	}
+
+	if track.Title == "" || track.Artist == "" {
+		return nil, errors.New("the lyrics require [ti:] and [ar:] tags or an \"Artist - Title\" query")
+	}
+
+	if track.Album == "" || track.Length == 0 {
+		if playing != nil && LyricsKey(playing) != LyricsKey(track) {
+			return nil, fmt.Errorf("the lyrics are for %s - %s but %s - %s is playing", track.Artist, track.Title, playing.Artist, playing.Title)
+		}
+		if playing != nil {
+			track.Album = cmp.Or(track.Album, playing.Album)
+			track.Length = cmp.Or(track.Length, playing.Length)
+		}
+	}
+
+	if track.Album == "" || track.Length == 0 {
+		return nil, errors.New("LrcLib requires album and duration of the track")
+	}
+	track.ID = StringToMD5(track.Artist + track.Title)
+	return track, nil
+}
+
+// playingTrack returns the track of the player
+func playingTrack() (*PlayerInfo, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dbus connection: %w", err)
+	}
+
+	player, err := FindPlayer(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := GetSpotifyInfo(player)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track metadata: %w", err)
+	}
+	return info, nil
+}
+
+// Publish publishes lyrics from the cache or a lyrics file to LrcLib. The track
+// is taken from the tags of the lyrics and the query, and completed with the
+// metadata of the playing track.
+func Publish(query string) error {
+	playing, err := playingTrack()
+	if err != nil {
+		// Lyrics of a file or another track don't need the player
+		if query == "" {
+			return err
+		}
+		slog.Debug("No playing track", "error", err)
+	}
+
+	lyrics, metadata, err := ResolveLyrics(query, playing)
+	if err != nil {
+		return err
+	}
+
+	info, err := publishTrack(query, metadata, playing)
+	if err != nil {
+		return err
+	}
+
+	var synced strings.Builder
+	// Other clients of LrcLib only read line-level LRC
+	if err := EncodeSimpleLRC(&synced, lyrics); err != nil {
+		return err
+	}
+
+	payload := &LrcLibPublish{
+		TrackName:    info.Title,
+		ArtistName:   info.Artist,
+		AlbumName:    info.Album,
+		Duration:     info.Length.Seconds(),
+		PlainLyrics:  PlainLyrics(lyrics),
+		SyncedLyrics: synced.String(),
+	}
+
+	if DryRun {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		e.SetEscapeHTML(false)
+		return e.Encode(payload)
+	}
+
+	if err := PublishLyrics(payload); err != nil {
+		return err
+	}
+
+	// Show the published lyrics instead of the previously cached ones
+	uri := LyricsKey(info)
+	if err := SaveCache(lyrics, metadata, filepath.Join(CacheDir, uri+".csv")); err != nil {
+		slog.Warn("Failed to cache published lyrics", "error", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Published lyrics for %s - %s\n", info.Artist, info.Title)
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSolveChallenge(t *testing.T) {
+	prefix := "VXMwW2qPfW2gkCNSl1i708NJkDghtAyU"
+	target := "000FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF"
+
+	nonce, err := SolveChallenge(prefix, target)
+	if err != nil {
+		t.Fatalf("SolveChallenge() failed: %v", err)
+	}
+
+	hash := sha256.Sum256([]byte(prefix + nonce))
+	want, _ := hex.DecodeString(target)
+	if bytes.Compare(hash[:], want) > 0 {
+		t.Errorf("SolveChallenge() = %s, hash %x is above target", nonce, hash)
+	}
+
+	if _, err := SolveChallenge(prefix, "zz"); err == nil {
+		t.Error("SolveChallenge() succeeded with invalid target")
+	}
+}
+
+func TestPublishTrack(t *testing.T) {
+	playing := &PlayerInfo{ID: "/track/1", Artist: "Queen", Title: "Love of My Life", Album: "A Night at the Opera", Length: 219 * time.Second}
+	tags := Metadata{"ar": "ABBA", "ti": "Waterloo", "al": "Waterloo", "length": "02:42.00"}
+
+	// The name of a lyrics file looks like an "Artist - Title" query
+	file := filepath.Join(t.TempDir(), "Queen - Love of My Life.lrc")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		metadata Metadata
+		playing  *PlayerInfo
+		want     *PlayerInfo
+		wantErr  bool
+	}{
+		{
+			name:    "Cached lyrics of the playing track",
+			playing: playing,
+			want:    playing,
+		},
+		{
+			name:     "Tags of a file without a player",
+			query:    "waterloo.lrc",
+			metadata: tags,
+			want:     &PlayerInfo{Artist: "ABBA", Title: "Waterloo", Album: "Waterloo", Length: 162 * time.Second},
+		},
+		{
+			name:     "Tags of a file while another track plays",
+			query:    "waterloo.lrc",
+			metadata: tags,
+			playing:  playing,
+			want:     &PlayerInfo{Artist: "ABBA", Title: "Waterloo", Album: "Waterloo", Length: 162 * time.Second},
+		},
+		{
+			name:     "Missing tags from the same track",
+			query:    "love.lrc",
+			metadata: Metadata{"ar": "queen", "ti": "Love of My Life"},
+			playing:  playing,
+			want:     &PlayerInfo{Artist: "queen", Title: "Love of My Life", Album: "A Night at the Opera", Length: 219 * time.Second},
+		},
+		{
+			name:     "Missing tags from another track",
+			query:    "waterloo.lrc",
+			metadata: Metadata{"ar": "ABBA", "ti": "Waterloo"},
+			playing:  playing,
+			wantErr:  true,
+		},
+		{
+			name:    "Query of another track",
+			query:   "ABBA - Waterloo",
+			playing: playing,
+			wantErr: true,
+		},
+		{
+			name:    "File without tags",
+			query:   "lyrics.lrc",
+			wantErr: true,
+		},
+		{
+			name:     "File named like a query",
+			query:    file,
+			metadata: tags,
+			playing:  playing,
+			want:     &PlayerInfo{Artist: "ABBA", Title: "Waterloo", Album: "Waterloo", Length: 162 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := publishTrack(tt.query, tt.metadata, tt.playing)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("publishTrack() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("publishTrack() failed: %v", err)
+			}
+			if got.Artist != tt.want.Artist || got.Title != tt.want.Title || got.Album != tt.want.Album || got.Length != tt.want.Length {
+				t.Errorf("publishTrack() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
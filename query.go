@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LoadLyricsFile reads and parses a local LRC, SRT, WebVTT or TTML file. The
+// format is detected from the content. LRC files are parsed with
+// ParseLyricsStrict in strict mode.
+func LoadLyricsFile(path string) (Lyrics, Metadata, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if StrictParse && DetectLyricsFormat(string(content)) == FormatLRC {
+		lyrics, metadata, err := ParseLyricsStrict(string(content))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%w", path, err)
+		}
+		return lyrics, metadata, nil
+	}
+
+	return ParseAny(string(content))
+}
+
+// isFile reports whether path is an existing regular file
+func isFile(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && !stat.IsDir()
+}
+
+// ResolveLyrics finds the lyrics described by query. The query can be a path to
+// a lyrics file, an "Artist - Title" string or a cache key. An empty query
+// refers to the track in info.
+func ResolveLyrics(query string, info *PlayerInfo) (Lyrics, Metadata, error) {
+	if query == "" {
+		if info == nil {
+			return nil, nil, fmt.Errorf("no track is playing")
+		}
+		return LoadCache(lyricsCacheFile(info))
+	}
+
+	if isFile(query) {
+		return LoadLyricsFile(query)
+	}
+
+	key := query
+	if track, ok := trackFromName(query); ok {
+		key = LyricsKey(track)
+	}
+
+	lyrics, metadata, err := LoadCache(filepath.Join(CacheDir, filepath.Base(key)+".csv"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find %q in cache: %w", query, err)
+	}
+	return lyrics, metadata, nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scripts which can be romanized with the --romanize flag
+const (
+	ScriptKana     = "kana"
+	ScriptHangul   = "hangul"
+	ScriptCyrillic = "cyrillic"
+)
+
+// RomanizeScripts are the values of the --romanize flag
+var RomanizeScripts = []string{ScriptKana, ScriptHangul, ScriptCyrillic}
+
+// RomanizeLyrics returns a copy of lyrics with the text of every line, word and
+// background line romanized in the scripts selected by Romanize. The original
+// text is kept in LyricLine.Original.
+func RomanizeLyrics(lyrics Lyrics) Lyrics {
+	if len(Romanize) == 0 {
+		return lyrics
+	}
+
+	romanized := make(Lyrics, len(lyrics))
+	for i, line := range lyrics {
+		romanized[i] = romanizeLine(line)
+		if bg := line.Background; bg != nil {
+			b := romanizeLine(*bg)
+			romanized[i].Background = &b
+		}
+	}
+	return romanized
+}
+
+func romanizeLine(line LyricLine) LyricLine {
+	text := RomanizeText(line.Text)
+	if text == line.Text {
+		return line
+	}
+
+	line.Original = line.Text
+	line.Text = text
+	if line.Words != nil {
+		line.Words = romanizeWords(line.Words, text)
+	}
+	return line
+}
+
+// romanizeWords romanizes words with the words before them, so sounds linked
+// across a word boundary, like a Hangul final consonant or a kana っ, are
+// romanized like in the whole line. Each word gets the part of the romanized
+// line it adds. The words are dropped when their romanization can't be split,
+// since karaoke highlights the runes of the words in the line text.
+func romanizeWords(words []LyricWord, text string) []LyricWord {
+	romanized := make([]LyricWord, len(words))
+	var original strings.Builder
+	prev := ""
+	for i, word := range words {
+		original.WriteString(word.Text)
+		current := RomanizeText(original.String())
+		if !strings.HasPrefix(current, prev) {
+			return nil
+		}
+		romanized[i] = LyricWord{Timestamp: word.Timestamp, Text: current[len(prev):]}
+		prev = current
+	}
+
+	if strings.TrimSpace(prev) != text {
+		return nil
+	}
+	return romanized
+}
+
+// RomanizeText romanizes text in the scripts selected by Romanize
+func RomanizeText(text string) string {
+	if slices.Contains(Romanize, ScriptKana) {
+		text = RomanizeKana(text)
+	}
+	if slices.Contains(Romanize, ScriptHangul) {
+		text = RomanizeHangul(text)
+	}
+	if slices.Contains(Romanize, ScriptCyrillic) {
+		text = RomanizeCyrillic(text, CyrillicScheme)
+	}
+	return text
+}
+
+// kanaDigraphs are the Hepburn romanizations of kana followed by a small ya, yu
+// or yo
+var kanaDigraphs = map[string]string{
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho", "しぇ": "she",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "ちぇ": "che",
+	"にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo",
+	"みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+	"ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "じぇ": "je",
+	"ぢゃ": "ja", "ぢゅ": "ju", "ぢょ": "jo",
+	"びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+	"ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo",
+	"てぃ": "ti", "でぃ": "di", "とぅ": "tu", "どぅ": "du",
+	"うぃ": "wi", "うぇ": "we", "うぉ": "wo", "ゔぁ": "va",
+	"ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo", "つぁ": "tsa",
+}
+
+// kanaSyllables are the Hepburn romanizations of single hiragana
+var kanaSyllables = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa", 'ゔ': "vu",
+	'、': ", ", '。': ". ", '「': "\"", '」': "\"", '・': " ",
+	'！': "!", '？': "?", '　': " ",
+}
+
+// toHiragana converts a katakana rune to hiragana
+func toHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - 0x60
+	}
+	return r
+}
+
+// RomanizeKana romanizes hiragana and katakana with the Hepburn system. Kanji
+// are kept as they are.
+func RomanizeKana(text string) string {
+	runes := []rune(text)
+
+	var out strings.Builder
+	// geminate is set after a small tsu which doubles the next consonant
+	geminate := false
+	for i := 0; i < len(runes); i++ {
+		r := toHiragana(runes[i])
+
+		var roman string
+		if i+1 < len(runes) {
+			roman = kanaDigraphs[string([]rune{r, toHiragana(runes[i+1])})]
+		}
+		if roman != "" {
+			i++
+		} else if r == 'っ' {
+			geminate = true
+			continue
+		} else if r == 'ー' {
+			// The long vowel mark repeats the previous vowel
+			if s := out.String(); s != "" && strings.ContainsRune("aeiou", rune(s[len(s)-1])) {
+				out.WriteByte(s[len(s)-1])
+			}
+			continue
+		} else if s, ok := kanaSyllables[r]; ok {
+			roman = s
+			// "n'" separates ん from a following vowel or y
+			if r == 'ん' && i+1 < len(runes) {
+				if next := kanaSyllables[toHiragana(runes[i+1])]; next != "" && strings.ContainsRune("aeiouy", rune(next[0])) {
+					roman = "n'"
+				}
+			}
+		} else {
+			geminate = false
+			out.WriteRune(runes[i])
+			continue
+		}
+
+		if geminate {
+			if strings.HasPrefix(roman, "ch") {
+				out.WriteByte('t')
+			} else if !strings.ContainsRune("aeioun", rune(roman[0])) && unicode.IsLetter(rune(roman[0])) {
+				out.WriteByte(roman[0])
+			}
+			geminate = false
+		}
+		out.WriteString(roman)
+	}
+	return out.String()
+}
+
+// Revised Romanization of the jamo of a Hangul syllable
+var (
+	hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	hangulVowels   = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	hangulFinals   = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+	// hangulLinked is the sound of a final consonant moved to the next syllable
+	// when it starts with a silent ㅇ
+	hangulLinked = []string{"", "g", "kk", "ks", "n", "nj", "n", "d", "r", "lg", "lm", "lb", "ls", "lt", "lp", "r", "m", "b", "bs", "s", "ss", "ng", "j", "ch", "k", "t", "p", ""}
+)
+
+const (
+	hangulBase    = 0xAC00
+	hangulLast    = 0xD7A3
+	hangulSilent  = 11 // ㅇ as initial
+	hangulRieul   = 5  // ㄹ as initial
+	hangulFinalL  = 8  // ㄹ as final
+	hangulFinalNG = 21 // ㅇ as final
+)
+
+// decomposeHangul splits a Hangul syllable into its initial, vowel and final
+// jamo indexes
+func decomposeHangul(r rune) (int, int, int, bool) {
+	if r < hangulBase || r > hangulLast {
+		return 0, 0, 0, false
+	}
+	s := int(r - hangulBase)
+	return s / (21 * 28), (s % (21 * 28)) / 28, s % 28, true
+}
+
+// RomanizeHangul romanizes Hangul with the Revised Romanization of Korean. The
+// final consonant is linked to a following silent ㅇ and ㄹㄹ is written as
+// "ll"; other sound changes are not applied.
+func RomanizeHangul(text string) string {
+	runes := []rune(text)
+
+	var out strings.Builder
+	for i, r := range runes {
+		initial, vowel, final, ok := decomposeHangul(r)
+		if !ok {
+			out.WriteRune(r)
+			continue
+		}
+
+		// The final consonant of the previous syllable changes this initial
+		onset := hangulInitials[initial]
+		if i > 0 {
+			if _, _, prevFinal, ok := decomposeHangul(runes[i-1]); ok {
+				switch {
+				case initial == hangulSilent && prevFinal != 0 && prevFinal != hangulFinalNG:
+					onset = ""
+				case initial == hangulRieul && prevFinal == hangulFinalL:
+					onset = "l"
+				}
+			}
+		}
+
+		coda := hangulFinals[final]
+		if i+1 < len(runes) && final != 0 && final != hangulFinalNG {
+			if nextInitial, _, _, ok := decomposeHangul(runes[i+1]); ok && nextInitial == hangulSilent {
+				coda = hangulLinked[final]
+			}
+		}
+
+		out.WriteString(onset + hangulVowels[vowel] + coda)
+	}
+	return out.String()
+}
+
+// CyrillicSchemes are the values of the --cyrillic-scheme flag
+var CyrillicSchemes = []string{"bgn", "iso9", "scholarly"}
+
+// cyrillicCommon is the romanization shared by every scheme
+var cyrillicCommon = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'з': "z",
+	'и': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p",
+	'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'ы': "y",
+	'і': "i", 'ґ': "g",
+}
+
+// cyrillicSchemes are the letters which differ between schemes
+var cyrillicSchemes = map[string]map[rune]string{
+	"bgn": {
+		'ё': "ë", 'ж': "zh", 'й': "y", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh",
+		'щ': "shch", 'ъ': "\"", 'ь': "'", 'э': "e", 'ю': "yu", 'я': "ya",
+		'є': "ye", 'ї': "yi", 'ў': "w",
+	},
+	"iso9": {
+		'ё': "ë", 'ж': "ž", 'й': "j", 'х': "h", 'ц': "c", 'ч': "č", 'ш': "š",
+		'щ': "ŝ", 'ъ': "ʺ", 'ь': "ʹ", 'э': "è", 'ю': "û", 'я': "â",
+		'є': "ê", 'ї': "ï", 'ў': "ǔ",
+	},
+	"scholarly": {
+		'ё': "ë", 'ж': "ž", 'й': "j", 'х': "x", 'ц': "c", 'ч': "č", 'ш': "š",
+		'щ': "šč", 'ъ': "ʺ", 'ь': "ʹ", 'э': "è", 'ю': "ju", 'я': "ja",
+		'є': "je", 'ї': "ji", 'ў': "ŭ",
+	},
+}
+
+// RomanizeCyrillic romanizes Russian, Ukrainian and Belarusian Cyrillic with
+// scheme, one of CyrillicSchemes. Unknown schemes fall back to "bgn".
+func RomanizeCyrillic(text, scheme string) string {
+	table, ok := cyrillicSchemes[scheme]
+	if !ok {
+		table = cyrillicSchemes["bgn"]
+	}
+
+	runes := []rune(text)
+
+	var out strings.Builder
+	for i, r := range runes {
+		lower := unicode.ToLower(r)
+		roman, ok := cyrillicCommon[lower]
+		if !ok {
+			roman, ok = table[lower]
+		}
+		if !ok {
+			out.WriteRune(r)
+			continue
+		}
+
+		if r != lower && roman != "" {
+			// Keep all caps words in all caps
+			nextUpper := i+1 < len(runes) && unicode.IsUpper(runes[i+1])
+			prevUpper := i > 0 && unicode.IsUpper(runes[i-1])
+			if nextUpper || prevUpper {
+				roman = strings.ToUpper(roman)
+			} else {
+				first, size := utf8.DecodeRuneInString(roman)
+				roman = string(unicode.ToUpper(first)) + roman[size:]
+			}
+		}
+		out.WriteString(roman)
+	}
+	return out.String()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRomanizeKana(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "こんにちは", want: "konnichiha"},
+		{text: "きょうと", want: "kyouto"},
+		{text: "ちょっと", want: "chotto"},
+		{text: "マッチ", want: "matchi"},
+		{text: "ラーメン", want: "raamen"},
+		{text: "しんや", want: "shin'ya"},
+		{text: "君のなまえ", want: "君nonamae"},
+		{text: "Hello、ワールド", want: "Hello, waarudo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := RomanizeKana(tt.text); got != tt.want {
+				t.Errorf("RomanizeKana() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRomanizeHangul(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "안녕하세요", want: "annyeonghaseyo"},
+		{text: "한국어", want: "hangugeo"},
+		{text: "사랑해", want: "saranghae"},
+		{text: "서울", want: "seoul"},
+		{text: "별로", want: "byeollo"},
+		{text: "BTS 노래", want: "BTS norae"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := RomanizeHangul(tt.text); got != tt.want {
+				t.Errorf("RomanizeHangul() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRomanizeCyrillic(t *testing.T) {
+	tests := []struct {
+		text   string
+		scheme string
+		want   string
+	}{
+		{text: "Привет, мир", scheme: "bgn", want: "Privet, mir"},
+		{text: "Щука и жук", scheme: "bgn", want: "Shchuka i zhuk"},
+		{text: "Щука и жук", scheme: "iso9", want: "Ŝuka i žuk"},
+		{text: "Юрий Хрущёв", scheme: "scholarly", want: "Jurij Xruščëv"},
+		{text: "ЦОЙ ЖИВ", scheme: "bgn", want: "TSOY ZHIV"},
+		{text: "Їжак", scheme: "unknown", want: "Yizhak"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scheme+" "+tt.text, func(t *testing.T) {
+			if got := RomanizeCyrillic(tt.text, tt.scheme); got != tt.want {
+				t.Errorf("RomanizeCyrillic() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRomanizeLyrics(t *testing.T) {
+	defer func(scripts []string) { Romanize = scripts }(Romanize)
+	Romanize = []string{ScriptHangul}
+
+	lyrics := Lyrics{{
+		Text:  "사랑 해",
+		Words: []LyricWord{{Text: "사랑 "}, {Timestamp: 1, Text: "해"}},
+	}}
+
+	got := RomanizeLyrics(lyrics)[0]
+	if got.Text != "sarang hae" || got.Original != "사랑 해" {
+		t.Errorf("RomanizeLyrics() = %+v", got)
+	}
+	if got.Words[0].Text != "sarang " || got.Words[1].Text != "hae" {
+		t.Errorf("RomanizeLyrics() words = %+v", got.Words)
+	}
+	if lyrics[0].Text != "사랑 해" {
+		t.Error("RomanizeLyrics() changed the original lyrics")
+	}
+}
+
+func TestRomanizeLinkedWords(t *testing.T) {
+	defer func(scripts []string) { Romanize = scripts }(Romanize)
+	Romanize = []string{ScriptKana, ScriptHangul}
+
+	tests := []struct {
+		name  string
+		words []string
+		text  string
+		want  []string
+	}{
+		{name: "Gemination across words", words: []string{"ちょっ", "と"}, text: "chotto", want: []string{"cho", "tto"}},
+		{name: "Long vowel across words", words: []string{"ラ", "ーメン"}, text: "raamen", want: []string{"ra", "amen"}},
+		{name: "Final consonant linked to the next word", words: []string{"한국", "어"}, text: "hangugeo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := LyricLine{Text: strings.Join(tt.words, "")}
+			for i, word := range tt.words {
+				line.Words = append(line.Words, LyricWord{Timestamp: time.Duration(i) * time.Second, Text: word})
+			}
+
+			got := RomanizeLyrics(Lyrics{line})[0]
+			if got.Text != tt.text {
+				t.Errorf("RomanizeLyrics().Text = %q, want %q", got.Text, tt.text)
+			}
+
+			var words []string
+			var sung strings.Builder
+			for _, word := range got.Words {
+				words = append(words, word.Text)
+				sung.WriteString(word.Text)
+			}
+			if !slices.Equal(words, tt.want) {
+				t.Errorf("RomanizeLyrics().Words = %q, want %q", words, tt.want)
+			}
+			// Karaoke highlights the runes of the words in the text
+			if got.Words != nil && sung.String() != got.Text {
+				t.Errorf("romanized words %q don't make up the text %q", sung.String(), got.Text)
+			}
+		})
+	}
+}
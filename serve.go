@@ -0,0 +1,196 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//go:embed serve.html
+var servePage []byte
+
+// serveBuffer is the number of events queued for a slow stream client. Events
+// are dropped when it is full.
+const serveBuffer = 16
+
+// serveTrack is the track in the JSON of the HTTP server. Times are
+// milliseconds.
+type serveTrack struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	Length int64  `json:"length"`
+}
+
+// serveState is the JSON of the state sent by the HTTP server. Times are
+// milliseconds.
+type serveState struct {
+	Event    Event       `json:"event"`
+	Time     int64       `json:"time"`
+	Player   string      `json:"player"`
+	State    string      `json:"state"`
+	Provider string      `json:"provider"`
+	Track    *serveTrack `json:"track"`
+	Position int64       `json:"position"`
+	Offset   int64       `json:"offset"`
+	Index    int         `json:"index"`
+	Line     *jsonLine   `json:"line"`
+	Next     *jsonLine   `json:"next"`
+	// Lyrics are only sent with the events which change them and are null in
+	// the other events. A track without lyrics has an empty list.
+	Lyrics []jsonLine `json:"lyrics"`
+}
+
+// newServeState converts s to JSON. Time is when the event happened and
+// Position the position of the player at that time.
+func newServeState(event Event, s State, withLyrics bool) serveState {
+	out := serveState{
+		Event:    event,
+		Time:     time.Now().UnixMilli(),
+		Player:   s.Player,
+		State:    s.Status,
+		Provider: s.Provider,
+		Offset:   s.Offset.Milliseconds(),
+		Index:    s.Index,
+	}
+
+	if s.Track != nil {
+		out.Track = &serveTrack{
+			ID:     s.Track.ID,
+			Title:  s.Track.Title,
+			Artist: s.Track.Artist,
+			Album:  s.Track.Album,
+			Length: s.Track.Length.Milliseconds(),
+		}
+		out.Position = s.Track.Position.Milliseconds()
+	}
+	if line := s.Line(); line != nil {
+		l := toJSONLine(*line)
+		out.Line = &l
+	}
+	if line := s.Next(); line != nil {
+		l := toJSONLine(*line)
+		out.Next = &l
+	}
+
+	if withLyrics {
+		out.Lyrics = make([]jsonLine, len(s.Lyrics))
+		for i, line := range s.Lyrics {
+			out.Lyrics[i] = toJSONLine(line)
+		}
+	}
+	return out
+}
+
+// eventStream sends the state events to the clients of /events
+type eventStream struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+// publish queues the event for every client
+func (e *eventStream) publish(event Event, s State) {
+	withLyrics := event == EventTrack || event == EventLyrics
+	data, err := json.Marshal(newServeState(event, s, withLyrics))
+	if err != nil {
+		slog.Error("Failed to encode state", "error", err)
+		return
+	}
+	msg := fmt.Appendf(nil, "event: %s\ndata: %s\n\n", event, data)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for client := range e.clients {
+		select {
+		case client <- msg:
+		default:
+			slog.Debug("Dropped event of a slow client", "event", event)
+		}
+	}
+}
+
+// ServeHTTP streams the state events as server-sent events. The first event
+// is "state" with the whole state.
+func (e *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan []byte, serveBuffer)
+	e.mu.Lock()
+	e.clients[client] = struct{}{}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.clients, client)
+		e.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	data, _ := json.Marshal(newServeState(EventState, CurrentState(), true))
+	fmt.Fprintf(w, "event: state\ndata: %s\n\n", data)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-client:
+			if _, err := w.Write(msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// NewServeMux creates the handler of the HTTP server:
+//
+//	GET /        HTML page which shows the lyrics
+//	GET /state   JSON of the current state with the lyrics
+//	GET /events  server-sent events of the state
+//
+// The returned function stops the events.
+func NewServeMux() (http.Handler, func()) {
+	stream := &eventStream{clients: map[chan []byte]struct{}{}}
+	unsubscribe := Subscribe(stream.publish)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(servePage)
+	})
+	mux.HandleFunc("GET /state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newServeState(EventState, CurrentState(), true))
+	})
+	mux.Handle("GET /events", stream)
+	return mux, unsubscribe
+}
+
+// StartServer serves the state on addr in the background
+func StartServer(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Serving lyrics", "address", "http://"+l.Addr().String())
+	mux, _ := NewServeMux()
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			slog.Error("Failed to serve lyrics", "error", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	defer UpdateState(EventState, func(s *State) { *s = State{Index: -1} })
+	UpdateState(EventLyrics, func(s *State) {
+		s.Status = "playing"
+		s.Track = &PlayerInfo{ID: "id", Title: "Title", Artist: "Artist", Position: 1500 * time.Millisecond}
+		s.Lyrics = Lyrics{{Timestamp: time.Second, Text: "one"}, {Timestamp: 2 * time.Second, Text: "two"}}
+		s.Index = 0
+	})
+
+	mux, unsubscribe := NewServeMux()
+	defer unsubscribe()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/state")
+	if err != nil {
+		t.Fatalf("GET /state error = %v", err)
+	}
+	var state serveState
+	err = json.NewDecoder(resp.Body).Decode(&state)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if state.Line.Text != "one" || state.Next.Text != "two" || len(state.Lyrics) != 2 || state.Position != 1500 {
+		t.Errorf("GET /state = %+v", state)
+	}
+
+	resp, err = http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatalf("GET /events error = %v", err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+
+	// readEvent returns the name and the data of the next event
+	readEvent := func() (string, serveState) {
+		var name string
+		var data serveState
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("ReadString() error = %v", err)
+			}
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+			case line == "\n":
+				return name, data
+			}
+		}
+	}
+
+	if name, data := readEvent(); name != "state" || len(data.Lyrics) != 2 {
+		t.Errorf("first event = %q, %+v", name, data)
+	}
+
+	UpdateState(EventLine, func(s *State) { s.Index = 1 })
+	name, data := readEvent()
+	if name != "line" || data.Index != 1 || data.Line.Text != "two" || data.Lyrics != nil {
+		t.Errorf("line event = %q, %+v", name, data)
+	}
+
+	// A track without lyrics clears the lyrics of the previous track
+	UpdateState(EventLyrics, func(s *State) { s.Lyrics, s.Index = nil, -1 })
+	name, data = readEvent()
+	if name != "lyrics" || data.Lyrics == nil || len(data.Lyrics) != 0 {
+		t.Errorf("lyrics event without lyrics = %q, %+v", name, data)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Nadim147c/go-mpris"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	// ServiceName is the bus name and the interface of the D-Bus service
+	ServiceName = "io.github.waybar_lyric"
+	// ServicePath is the object path of the D-Bus service
+	ServicePath = dbus.ObjectPath("/io/github/waybar_lyric")
+)
+
+// ErrNoTrack is returned by the commands which need a track when no track is
+// playing
+var ErrNoTrack = errors.New("no track is playing")
+
+// serviceLine is a line of the Lyrics property: the timestamp in microseconds
+// and the text
+type serviceLine struct {
+	Timestamp int64
+	Text      string
+}
+
+// serviceLyrics converts lyrics to the Lyrics property
+func serviceLyrics(lyrics Lyrics) []serviceLine {
+	lines := make([]serviceLine, len(lyrics))
+	for i, line := range lyrics {
+		lines[i] = serviceLine{Timestamp: line.Timestamp.Microseconds(), Text: line.Text}
+	}
+	return lines
+}
+
+// serviceTrack converts the track to the Track property, an empty map without
+// a track
+func serviceTrack(info *PlayerInfo) map[string]dbus.Variant {
+	track := map[string]dbus.Variant{}
+	if info == nil {
+		return track
+	}
+	track["id"] = dbus.MakeVariant(info.ID)
+	track["title"] = dbus.MakeVariant(info.Title)
+	track["artist"] = dbus.MakeVariant(info.Artist)
+	track["album"] = dbus.MakeVariant(info.Album)
+	track["length"] = dbus.MakeVariant(info.Length.Microseconds())
+	return track
+}
+
+// serviceProperties returns the values of the properties of the service
+func serviceProperties(s State) map[string]any {
+	current, next := "", ""
+	if line := s.Line(); line != nil {
+		current = line.Text
+	}
+	if line := s.Next(); line != nil {
+		next = line.Text
+	}
+
+	return map[string]any{
+		"CurrentLine": current,
+		"NextLine":    next,
+		"Index":       int32(s.Index),
+		"Lyrics":      serviceLyrics(s.Lyrics),
+		"Track":       serviceTrack(s.Track),
+		"State":       s.Status,
+		"Provider":    s.Provider,
+		"Player":      s.Player,
+		"Offset":      s.Offset.Milliseconds(),
+	}
+}
+
+// service implements the methods of the D-Bus service
+type service struct {
+	conn *dbus.Conn
+}
+
+// send sends cmd to the main loop
+func (service) send(cmd Command) *dbus.Error {
+	select {
+	case Commands <- cmd:
+		return nil
+	default:
+		return dbus.MakeFailedError(fmt.Errorf("too many pending commands"))
+	}
+}
+
+// Refetch fetches the lyrics of the current track again
+func (s service) Refetch() *dbus.Error {
+	return s.send(Command{Refetch: true})
+}
+
+// AdjustOffset adds ms milliseconds to the saved offset of the track
+func (s service) AdjustOffset(ms int64) *dbus.Error {
+	if CurrentState().Track == nil {
+		return dbus.MakeFailedError(ErrNoTrack)
+	}
+	return s.send(Command{Offset: time.Duration(ms) * time.Millisecond})
+}
+
+// FollowPlayer follows the player with the bus name or the name after
+// "org.mpris.MediaPlayer2."
+func (s service) FollowPlayer(name string) *dbus.Error {
+	players, err := mpris.List(s.conn)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	if !strings.HasPrefix(name, mprisPrefix) {
+		name = mprisPrefix + name
+	}
+	for _, player := range players {
+		if player == name {
+			return s.send(Command{Player: name})
+		}
+	}
+	return dbus.MakeFailedError(fmt.Errorf("player not found: %s", name))
+}
+
+// serviceIntrospection is the introspection data of the service interface
+func serviceIntrospection(props *prop.Properties) introspect.Node {
+	return introspect.Node{
+		Name: string(ServicePath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name: ServiceName,
+				Methods: []introspect.Method{
+					{Name: "Refetch"},
+					{Name: "AdjustOffset", Args: []introspect.Arg{{Name: "milliseconds", Type: "x", Direction: "in"}}},
+					{Name: "FollowPlayer", Args: []introspect.Arg{{Name: "name", Type: "s", Direction: "in"}}},
+				},
+				Signals:    []introspect.Signal{{Name: "OffsetsChanged"}},
+				Properties: props.Introspection(ServiceName),
+			},
+		},
+	}
+}
+
+// StartService exports the state and the commands on the session bus as
+// io.github.waybar_lyric. Properties emit PropertiesChanged when the state
+// changes.
+func StartService(conn *dbus.Conn) error {
+	values := serviceProperties(CurrentState())
+	props := map[string]*prop.Prop{}
+	for name, value := range values {
+		props[name] = &prop.Prop{Value: value, Emit: prop.EmitTrue}
+	}
+
+	properties, err := prop.Export(conn, ServicePath, prop.Map{ServiceName: props})
+	if err != nil {
+		return fmt.Errorf("failed to export properties: %w", err)
+	}
+
+	if err := conn.Export(service{conn}, ServicePath, ServiceName); err != nil {
+		return fmt.Errorf("failed to export methods: %w", err)
+	}
+
+	node := serviceIntrospection(properties)
+	if err := conn.Export(introspect.NewIntrospectable(&node), ServicePath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("failed to export introspection: %w", err)
+	}
+
+	reply, err := conn.RequestName(ServiceName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %w", ServiceName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("%s is owned by another instance", ServiceName)
+	}
+
+	Subscribe(func(_ Event, s State) {
+		for name, value := range serviceProperties(s) {
+			if reflect.DeepEqual(properties.GetMust(ServiceName, name), value) {
+				continue
+			}
+			properties.SetMust(ServiceName, name, value)
+		}
+	})
+
+	slog.Info("D-Bus service started", "name", ServiceName)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServiceProperties(t *testing.T) {
+	state := State{
+		Status:   "playing",
+		Track:    &PlayerInfo{ID: "id", Title: "Title", Artist: "Artist", Length: time.Minute},
+		Lyrics:   Lyrics{{Timestamp: time.Second, Text: "one"}, {Timestamp: 2 * time.Second, Text: "two"}},
+		Index:    0,
+		Provider: ProviderLrcLib,
+		Offset:   -250 * time.Millisecond,
+	}
+
+	props := serviceProperties(state)
+	if props["CurrentLine"] != "one" || props["NextLine"] != "two" {
+		t.Errorf("lines = %q, %q, want %q, %q", props["CurrentLine"], props["NextLine"], "one", "two")
+	}
+	if props["Offset"] != int64(-250) {
+		t.Errorf("Offset = %v, want -250", props["Offset"])
+	}
+
+	lyrics := props["Lyrics"].([]serviceLine)
+	if len(lyrics) != 2 || lyrics[1] != (serviceLine{Timestamp: 2000000, Text: "two"}) {
+		t.Errorf("Lyrics = %v", lyrics)
+	}
+
+	// Before the first line and at the last line
+	state.Index = -1
+	if props := serviceProperties(state); props["CurrentLine"] != "" || props["NextLine"] != "one" {
+		t.Errorf("lines before the first line = %q, %q", props["CurrentLine"], props["NextLine"])
+	}
+	state.Index = 1
+	if props := serviceProperties(state); props["CurrentLine"] != "two" || props["NextLine"] != "" {
+		t.Errorf("lines at the last line = %q, %q", props["CurrentLine"], props["NextLine"])
+	}
+
+	if track := serviceProperties(State{Index: -1})["Track"]; track == nil {
+		t.Error("Track of an empty state is nil, want an empty map")
+	}
+}
+
+func TestAdjustOffset(t *testing.T) {
+	defer UpdateState(EventState, func(s *State) { *s = State{Index: -1} })
+
+	if err := (service{}).AdjustOffset(100); err == nil {
+		t.Error("AdjustOffset() without a track succeeded")
+	}
+	if len(Commands) != 0 {
+		t.Fatalf("AdjustOffset() without a track queued %d commands", len(Commands))
+	}
+
+	UpdateState(EventTrack, func(s *State) { s.Track = &PlayerInfo{Title: "Title", Artist: "Artist"} })
+	if err := (service{}).AdjustOffset(100); err != nil {
+		t.Fatalf("AdjustOffset() error = %v", err)
+	}
+	if cmd := <-Commands; cmd.Offset != 100*time.Millisecond {
+		t.Errorf("AdjustOffset() queued %+v, want an offset of 100ms", cmd)
+	}
+}
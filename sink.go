@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+)
+
+// pausedColor is the text color of a paused player on bars which are not
+// styled with CSS
+const pausedColor = "#aaaaaa"
+
+// StatusIcons are the icons of the states on bars without format-icons
+var StatusIcons = map[Status]string{
+	Playing: "",
+	Paused:  "",
+	Lyric:   "",
+	Music:   "󰝚",
+}
+
+// Sink writes the output for a status bar
+type Sink interface {
+	// Markup reports whether the bar reads Pango markup. Bars without markup
+	// don't get the bidi isolates either.
+	Markup() bool
+	// Write writes a single update
+	Write(w io.Writer, waybar *Waybar) error
+	// Clear writes an update which hides the module
+	Clear(w io.Writer) error
+}
+
+// OutputFormats are the values of the --output flag
+var OutputFormats = []string{"waybar", "i3bar", "polybar", "yambar", "eww", "plain"}
+
+// OutputSink is the sink selected with --output
+var OutputSink Sink = &WaybarSink{}
+
+// OutputWriter is where the output is written, the clients of the daemon or
+// stdout
+var OutputWriter io.Writer = os.Stdout
+
+// NewSink creates the sink of an output format
+func NewSink(format string) (Sink, error) {
+	switch format {
+	case "waybar":
+		return &WaybarSink{}, nil
+	case "i3bar":
+		return &I3barSink{}, nil
+	case "polybar":
+		return &PolybarSink{}, nil
+	case "yambar":
+		return &YambarSink{}, nil
+	case "eww":
+		return &EwwSink{}, nil
+	case "plain":
+		return &PlainSink{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// Encode writes w to OutputWriter with OutputSink
+func (w *Waybar) Encode() {
+	if err := OutputSink.Write(OutputWriter, w); err != nil {
+		slog.Error("Failed to write output", "error", err)
+	}
+}
+
+// ClearOutput hides the module
+func ClearOutput() {
+	if err := OutputSink.Clear(OutputWriter); err != nil {
+		slog.Error("Failed to write output", "error", err)
+	}
+}
+
+// State returns the player state of w, Playing or Paused
+func (w *Waybar) State() Status {
+	if w.Alt == Paused || slices.Contains(w.Class, Paused) {
+		return Paused
+	}
+	return Playing
+}
+
+// icon returns the text of w with the icon of its alt in front
+func (w *Waybar) icon() string {
+	return strings.TrimSpace(StatusIcons[w.Alt] + " " + singleLine(w.Text))
+}
+
+// singleLine joins the lines of text for line based outputs
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(text, "\n", " ")), " ")
+}
+
+// writeJSON writes v as a single line of JSON
+func writeJSON(w io.Writer, v any) error {
+	e := json.NewEncoder(w)
+	e.SetEscapeHTML(false)
+	return e.Encode(v)
+}
+
+// WaybarSink writes the JSON of waybar custom modules
+type WaybarSink struct{}
+
+func (s *WaybarSink) Markup() bool { return true }
+
+func (s *WaybarSink) Write(w io.Writer, waybar *Waybar) error {
+	return writeJSON(w, waybar)
+}
+
+func (s *WaybarSink) Clear(w io.Writer) error {
+	_, err := fmt.Fprintln(w, "{}")
+	return err
+}
+
+// I3barSink writes the i3bar protocol used by i3bar and swaybar: a header and
+// an endless array with a status line of a single block per update
+type I3barSink struct {
+	started bool
+}
+
+// i3barBlock is a block of the i3bar protocol
+type i3barBlock struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	FullText string `json:"full_text"`
+	Color    string `json:"color,omitempty"`
+	Markup   string `json:"markup,omitempty"`
+}
+
+func (s *I3barSink) Markup() bool { return true }
+
+func (s *I3barSink) write(w io.Writer, blocks []i3barBlock) error {
+	if !s.started {
+		if _, err := fmt.Fprint(w, "{\"version\":1}\n[\n"); err != nil {
+			return err
+		}
+	} else if _, err := fmt.Fprint(w, ","); err != nil {
+		return err
+	}
+	s.started = true
+	return writeJSON(w, blocks)
+}
+
+func (s *I3barSink) Write(w io.Writer, waybar *Waybar) error {
+	block := i3barBlock{
+		Name:     "waybar-lyric",
+		Instance: string(waybar.Alt),
+		FullText: waybar.icon(),
+	}
+	if waybar.State() == Paused {
+		block.Color = pausedColor
+	}
+	if !NoMarkup {
+		block.Markup = "pango"
+	}
+	return s.write(w, []i3barBlock{block})
+}
+
+func (s *I3barSink) Clear(w io.Writer) error {
+	return s.write(w, []i3barBlock{})
+}
+
+// PolybarSink writes lines with polybar formatting tags for a script module
+// with tail = true. Clicking the text toggles the player.
+type PolybarSink struct{}
+
+func (s *PolybarSink) Markup() bool { return false }
+
+func (s *PolybarSink) Write(w io.Writer, waybar *Waybar) error {
+	// Polybar reads %%{ as a literal %{ instead of a tag
+	text := strings.ReplaceAll(stripIsolates.Replace(waybar.icon()), "%{", "%%{")
+	if waybar.State() == Paused {
+		text = fmt.Sprintf("%%{F%s}%s%%{F-}", pausedColor, text)
+	}
+	_, err := fmt.Fprintf(w, "%%{A1:waybar-lyric --toggle:}%s%%{A}\n", text)
+	return err
+}
+
+func (s *PolybarSink) Clear(w io.Writer) error {
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// YambarSink writes the tags of a yambar script module. Every update is a
+// list of tag|type|value lines ended by an empty line.
+type YambarSink struct{}
+
+func (s *YambarSink) Markup() bool { return false }
+
+func (s *YambarSink) write(w io.Writer, waybar *Waybar) error {
+	direction := LTR
+	if slices.Contains(waybar.Class, RTL) {
+		direction = RTL
+	}
+
+	_, err := fmt.Fprintf(w,
+		"text|string|%s\nstate|string|%s\nalt|string|%s\ndirection|string|%s\nplaying|bool|%t\nprogress|range:0-100|%d\n\n",
+		singleLine(stripIsolates.Replace(waybar.Text)), waybar.State(), waybar.Alt, direction, waybar.State() == Playing, min(max(waybar.Percentage, 0), 100),
+	)
+	return err
+}
+
+func (s *YambarSink) Write(w io.Writer, waybar *Waybar) error {
+	return s.write(w, waybar)
+}
+
+func (s *YambarSink) Clear(w io.Writer) error {
+	return s.write(w, &Waybar{Alt: Music})
+}
+
+// EwwSink writes a JSON object per line for an eww deflisten variable. The
+// class is a space separated string which can be used in :class.
+type EwwSink struct{}
+
+// ewwOutput is the JSON object written by EwwSink
+type ewwOutput struct {
+	Text       string `json:"text"`
+	Tooltip    string `json:"tooltip"`
+	Class      string `json:"class"`
+	Alt        Status `json:"alt"`
+	State      Status `json:"state"`
+	Percentage int    `json:"percentage"`
+}
+
+func (s *EwwSink) Markup() bool { return false }
+
+func (s *EwwSink) Write(w io.Writer, waybar *Waybar) error {
+	classes := make([]string, len(waybar.Class))
+	for i, class := range waybar.Class {
+		classes[i] = string(class)
+	}
+
+	return writeJSON(w, ewwOutput{
+		Text:       stripIsolates.Replace(waybar.Text),
+		Tooltip:    stripIsolates.Replace(waybar.Tooltip),
+		Class:      strings.Join(classes, " "),
+		Alt:        waybar.Alt,
+		State:      waybar.State(),
+		Percentage: waybar.Percentage,
+	})
+}
+
+func (s *EwwSink) Clear(w io.Writer) error {
+	return writeJSON(w, ewwOutput{})
+}
+
+// PlainSink writes the text of every update as a line
+type PlainSink struct{}
+
+func (s *PlainSink) Markup() bool { return false }
+
+func (s *PlainSink) Write(w io.Writer, waybar *Waybar) error {
+	_, err := fmt.Fprintln(w, singleLine(stripIsolates.Replace(waybar.Text)))
+	return err
+}
+
+func (s *PlainSink) Clear(w io.Writer) error {
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSinks(t *testing.T) {
+	playing := &Waybar{
+		Text:       "Hello\nworld",
+		Tooltip:    "tooltip",
+		Alt:        Lyric,
+		Class:      Class{Lyric, Playing, LTR},
+		Percentage: 42,
+	}
+	paused := &Waybar{Text: "Artist - Title", Alt: Paused, Class: Class{Paused, LTR}}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "waybar",
+			want: `{"text":"Hello\nworld","class":["lyric","playing","ltr"],"alt":"lyric","tooltip":"tooltip","percentage":42}` + "\n" +
+				`{"text":"Artist - Title","class":["paused","ltr"],"alt":"paused","tooltip":"","percentage":0}` + "\n" +
+				"{}\n",
+		},
+		{
+			format: "i3bar",
+			want: "{\"version\":1}\n[\n" +
+				`[{"name":"waybar-lyric","instance":"lyric","full_text":"` + StatusIcons[Lyric] + ` Hello world","markup":"pango"}]` + "\n" +
+				`,[{"name":"waybar-lyric","instance":"paused","full_text":"` + StatusIcons[Paused] + ` Artist - Title","color":"#aaaaaa","markup":"pango"}]` + "\n" +
+				",[]\n",
+		},
+		{
+			format: "polybar",
+			want: "%{A1:waybar-lyric --toggle:}" + StatusIcons[Lyric] + " Hello world%{A}\n" +
+				"%{A1:waybar-lyric --toggle:}%{F#aaaaaa}" + StatusIcons[Paused] + " Artist - Title%{F-}%{A}\n" +
+				"\n",
+		},
+		{
+			format: "yambar",
+			want: "text|string|Hello world\nstate|string|playing\nalt|string|lyric\ndirection|string|ltr\nplaying|bool|true\nprogress|range:0-100|42\n\n" +
+				"text|string|Artist - Title\nstate|string|paused\nalt|string|paused\ndirection|string|ltr\nplaying|bool|false\nprogress|range:0-100|0\n\n" +
+				"text|string|\nstate|string|playing\nalt|string|music\ndirection|string|ltr\nplaying|bool|true\nprogress|range:0-100|0\n\n",
+		},
+		{
+			format: "eww",
+			want: `{"text":"Hello\nworld","tooltip":"tooltip","class":"lyric playing ltr","alt":"lyric","state":"playing","percentage":42}` + "\n" +
+				`{"text":"Artist - Title","tooltip":"","class":"paused ltr","alt":"paused","state":"paused","percentage":0}` + "\n" +
+				`{"text":"","tooltip":"","class":"","alt":"","state":"","percentage":0}` + "\n",
+		},
+		{
+			format: "plain",
+			want:   "Hello world\nArtist - Title\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			sink, err := NewSink(tt.format)
+			if err != nil {
+				t.Fatalf("NewSink() error = %v", err)
+			}
+
+			var out strings.Builder
+			for _, waybar := range []*Waybar{playing, paused} {
+				if err := sink.Write(&out, waybar); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := sink.Clear(&out); err != nil {
+				t.Fatalf("Clear() error = %v", err)
+			}
+
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewSink("dzen"); err == nil {
+		t.Error("NewSink() of an unknown format succeeded")
+	}
+}
+
+func TestSinksIsolates(t *testing.T) {
+	waybar := &Waybar{
+		Text:    RightToLeftIsolate + "مرحبا" + PopDirectionalIsolate,
+		Tooltip: LeftToRightIsolate + "Hello" + PopDirectionalIsolate,
+		Alt:     Lyric,
+		Class:   Class{Lyric, Playing, RTL},
+	}
+
+	for _, format := range []string{"waybar", "i3bar", "polybar", "yambar", "eww", "plain"} {
+		t.Run(format, func(t *testing.T) {
+			sink, err := NewSink(format)
+			if err != nil {
+				t.Fatalf("NewSink() error = %v", err)
+			}
+
+			var out strings.Builder
+			if err := sink.Write(&out, waybar); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			got := out.String()
+			hasIsolates := strings.ContainsAny(got, LeftToRightIsolate+RightToLeftIsolate+PopDirectionalIsolate)
+			if hasIsolates != sink.Markup() {
+				t.Errorf("output = %q, isolates = %v, want %v", got, hasIsolates, sink.Markup())
+			}
+		})
+	}
+}
@@ -10,11 +10,17 @@ import (
 func PrintSnippet() {
 	fmt.Fprintln(os.Stderr, `Put the following object in your waybar config:`)
 
+	// Waybar reads the output as markup unless it is told to escape it
+	escape, flags := "", ""
+	if NoMarkup {
+		escape, flags = "\n\t\"escape\": true,", " --no-markup"
+	}
+
 	snippet := fmt.Sprintf(`
 "custom/lyrics": {
 	"return-type": "json",
 	"format": "{icon} {0}",
-	"hide-empty-text": true,
+	"hide-empty-text": true,%s
 	"format-icons": {
 		"playing": "",
 		"paused": "",
@@ -22,10 +28,10 @@ func PrintSnippet() {
 		"music": "󰝚",
 	},
 	"exec-if": "which waybar-lyric",
-	"exec": "waybar-lyric --max-length %d",
+	"exec": "waybar-lyric --max-length %d%s",
 	"on-click": "waybar-lyric --toggle",
 },
-`, MaxTextLength)
+`, escape, MaxTextLength, flags)
 
 	cmd := exec.Command("which", "bat")
 	if err := cmd.Run(); err == nil {
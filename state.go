@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a change of the State
+type Event string
+
+const (
+	// EventTrack is sent when the player plays another track
+	EventTrack Event = "track"
+	// EventState is sent when the player starts, pauses, stops or is gone
+	EventState Event = "state"
+	// EventLyrics is sent when lyrics are loaded or not found
+	EventLyrics Event = "lyrics"
+	// EventLine is sent when the current line changes
+	EventLine Event = "line"
+	// EventOffset is sent when the lyrics offset changes
+	EventOffset Event = "offset"
+)
+
+// LyricsStatus is how the lyrics of the track were found
+type LyricsStatus string
+
+const (
+	LyricsFetched      LyricsStatus = "fetched"
+	LyricsFromCache    LyricsStatus = "cached"
+	LyricsUnsynced     LyricsStatus = "unsynced"
+	LyricsInstrumental LyricsStatus = "instrumental"
+	LyricsMissing      LyricsStatus = "missing"
+)
+
+// lyricsStatus returns the status of lyrics which GetLyrics failed to find
+func lyricsStatus(err error) LyricsStatus {
+	switch {
+	case errors.Is(err, ErrInstrumental):
+		return LyricsInstrumental
+	case errors.Is(err, ErrUnsynced):
+		return LyricsUnsynced
+	default:
+		return LyricsMissing
+	}
+}
+
+// State is the player and the lyrics shown by waybar-lyric
+type State struct {
+	// Player is the bus name of the followed player
+	Player string
+	// Status is "playing", "paused", "stopped" or empty without a player
+	Status string
+	// Track is nil without a track
+	Track *PlayerInfo
+	// Lyrics are nil when they are not loaded or not found
+	Lyrics Lyrics
+	// LyricsStatus is empty until the lyrics are loaded or not found
+	LyricsStatus LyricsStatus
+	// Index of the current line, -1 before the first line
+	Index    int
+	Provider string
+	// Offset is added to the position of the player
+	Offset time.Duration
+}
+
+// Line returns the current line or nil
+func (s State) Line() *LyricLine {
+	if s.Index < 0 || s.Index >= len(s.Lyrics) {
+		return nil
+	}
+	return &s.Lyrics[s.Index]
+}
+
+// Next returns the line after the current line or nil
+func (s State) Next() *LyricLine {
+	if s.Index+1 < 0 || s.Index+1 >= len(s.Lyrics) {
+		return nil
+	}
+	return &s.Lyrics[s.Index+1]
+}
+
+// Listener is called after every event. Listeners are called on the main loop
+// and must not block.
+type Listener func(event Event, state State)
+
+var (
+	stateMu      sync.Mutex
+	currentState = State{Index: -1}
+	listeners    []*Listener
+)
+
+// Subscribe adds a listener of the state events. The returned function removes
+// the listener.
+func Subscribe(l Listener) func() {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	listener := &l
+	listeners = append(listeners, listener)
+
+	return func() {
+		stateMu.Lock()
+		defer stateMu.Unlock()
+		// UpdateState may still call the listeners of the old slice
+		listeners = slices.DeleteFunc(slices.Clone(listeners), func(other *Listener) bool {
+			return other == listener
+		})
+	}
+}
+
+// CurrentState returns a copy of the current state
+func CurrentState() State {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return currentState
+}
+
+// UpdateState changes the current state with update and sends the event to
+// the listeners
+func UpdateState(event Event, update func(s *State)) {
+	stateMu.Lock()
+	update(&currentState)
+	state := currentState
+	ls := listeners
+	stateMu.Unlock()
+
+	for _, l := range ls {
+		(*l)(event, state)
+	}
+}
+
+// statusName returns the State.Status of a mpris playback status
+func statusName(info *PlayerInfo) string {
+	return strings.ToLower(string(info.Status))
+}
+
+// Command is a request to the main loop from the D-Bus service or other
+// controls
+type Command struct {
+	// Refetch fetches the lyrics of the track again
+	Refetch bool
+	// Offset is added to the offset of the track
+	Offset time.Duration
+	// Player is the name of the player to follow
+	Player string
+}
+
+// Commands are handled by the main loop between updates
+var Commands = make(chan Command, 8)
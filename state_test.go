@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestSubscribe(t *testing.T) {
+	defer UpdateState(EventState, func(s *State) { *s = State{Index: -1} })
+
+	var first, second []Event
+	unsubscribe := Subscribe(func(event Event, s State) { first = append(first, event) })
+	defer Subscribe(func(event Event, s State) { second = append(second, event) })()
+
+	UpdateState(EventTrack, func(s *State) {})
+	unsubscribe()
+	UpdateState(EventLine, func(s *State) {})
+
+	if len(first) != 1 || first[0] != EventTrack {
+		t.Errorf("events of the removed listener = %v, want [track]", first)
+	}
+	if len(second) != 2 {
+		t.Errorf("events of the other listener = %v, want [track line]", second)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LyricsFormat is the format of a lyrics file
+type LyricsFormat string
+
+const (
+	FormatLRC  LyricsFormat = "lrc"
+	FormatSRT  LyricsFormat = "srt"
+	FormatVTT  LyricsFormat = "vtt"
+	FormatTTML LyricsFormat = "ttml"
+	FormatJSON LyricsFormat = "json"
+)
+
+// DetectLyricsFormat guesses the format of a lyrics file from its content
+func DetectLyricsFormat(content string) LyricsFormat {
+	content = strings.TrimLeft(strings.TrimPrefix(content, "\ufeff"), " \t\r\n")
+
+	if strings.HasPrefix(content, "WEBVTT") {
+		return FormatVTT
+	}
+
+	if strings.HasPrefix(content, "<?xml") || strings.HasPrefix(content, "<tt") {
+		return FormatTTML
+	}
+
+	if strings.HasPrefix(content, "{") {
+		return FormatJSON
+	}
+
+	for line := range strings.SplitSeq(content, "\n") {
+		start, _, ok := strings.Cut(line, "-->")
+		if ok && strings.Contains(start, ",") {
+			return FormatSRT
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			return FormatLRC
+		}
+	}
+
+	return FormatLRC
+}
+
+// ParseAny detects the format of content and parses it
+func ParseAny(content string) ([]LyricLine, Metadata, error) {
+	switch DetectLyricsFormat(content) {
+	case FormatSRT:
+		lyrics, err := ParseSRT(content)
+		return lyrics, Metadata{}, err
+	case FormatVTT:
+		return ParseVTT(content)
+	case FormatTTML:
+		return ParseTTML(content)
+	case FormatJSON:
+		return ParseJSON(content)
+	default:
+		return ParseLyrics(content)
+	}
+}
+
+// subtitleCue is a single timed block of a SRT or WebVTT file
+type subtitleCue struct {
+	Start time.Duration
+	End   time.Duration
+	Lines []string
+	// Voice is the name of the first <v> tag of the cue
+	Voice string
+}
+
+// parseCueTiming parses a "00:00:01,000 --> 00:00:04,000 align:start" line
+func parseCueTiming(line string) (time.Duration, time.Duration, error) {
+	start, end, ok := strings.Cut(line, "-->")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid cue timing: %s", line)
+	}
+
+	// WebVTT cue settings follow the end timestamp
+	if fields := strings.Fields(end); len(fields) > 0 {
+		end = fields[0]
+	}
+
+	startTime, err := ParseTimestamp(strings.ReplaceAll(start, ",", "."))
+	if err != nil {
+		return 0, 0, err
+	}
+	endTime, err := ParseTimestamp(strings.ReplaceAll(end, ",", "."))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return startTime, endTime, nil
+}
+
+// stripCueTags removes styling tags like <i>, <c.yellow> or <v Singer> from a
+// cue line. Inline timestamps are kept so they can be parsed as word timings.
+func stripCueTags(line string) string {
+	var text strings.Builder
+	for {
+		start := strings.IndexByte(line, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(line[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		text.WriteString(line[:start])
+		if _, err := ParseTimestamp(line[start+1 : end]); err == nil {
+			text.WriteString(line[start : end+1])
+		}
+		line = line[end+1:]
+	}
+	text.WriteString(line)
+	return text.String()
+}
+
+// cueVoice returns the name of the voice tag of a cue line, e.g. "Singer" for
+// "<v.loud Singer>Hello</v>"
+func cueVoice(line string) string {
+	start := strings.Index(line, "<v")
+	if start < 0 {
+		return ""
+	}
+	end := strings.IndexByte(line[start:], '>')
+	if end < 0 {
+		return ""
+	}
+
+	tag := line[start+2 : start+end]
+	if tag == "" || (tag[0] != ' ' && tag[0] != '.') {
+		return ""
+	}
+	_, name, _ := strings.Cut(tag, " ")
+	return strings.TrimSpace(name)
+}
+
+// parseCues splits a subtitle file into cues. Blocks without a timing line
+// (SRT counters, WebVTT headers, NOTE, STYLE and REGION blocks) are skipped.
+func parseCues(content string) []subtitleCue {
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var cues []subtitleCue
+	for block := range strings.SplitSeq(content, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+
+		timing := slices.IndexFunc(lines, func(line string) bool {
+			return strings.Contains(line, "-->")
+		})
+		if timing < 0 || timing > 1 {
+			continue
+		}
+
+		start, end, err := parseCueTiming(lines[timing])
+		if err != nil {
+			continue
+		}
+
+		cue := subtitleCue{Start: start, End: end}
+		for _, line := range lines[timing+1:] {
+			if cue.Voice == "" {
+				cue.Voice = cueVoice(line)
+			}
+			if line = strings.TrimSpace(stripCueTags(line)); line != "" {
+				cue.Lines = append(cue.Lines, line)
+			}
+		}
+
+		cues = append(cues, cue)
+	}
+
+	slices.SortStableFunc(cues, func(a, b subtitleCue) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	return cues
+}
+
+// cuesToLyrics converts cues to lyrics lines. Lines of a cue are joined with a
+// space and an empty line is inserted when a cue ends before the next starts.
+func cuesToLyrics(cues []subtitleCue) ([]LyricLine, error) {
+	var lyrics []LyricLine
+	for i, cue := range cues {
+		text, words := ParseWords(strings.Join(cue.Lines, " "), cue.Start)
+		for j := range words {
+			words[j].Text = html.UnescapeString(words[j].Text)
+		}
+
+		lyrics = append(lyrics, LyricLine{
+			Timestamp: cue.Start,
+			Text:      html.UnescapeString(text),
+			Words:     words,
+			Voice:     cue.Voice,
+		})
+
+		if cue.End > cue.Start && (i+1 == len(cues) || cue.End < cues[i+1].Start) {
+			lyrics = append(lyrics, LyricLine{Timestamp: cue.End})
+		}
+	}
+
+	if len(lyrics) == 0 {
+		return lyrics, errors.New("Lyric lines are 0")
+	}
+
+	// Dual-language subtitles have a cue for each language
+	return MergeTranslations(lyrics), nil
+}
+
+// ParseSRT parses a SubRip subtitle file into lyrics lines
+func ParseSRT(content string) ([]LyricLine, error) {
+	return cuesToLyrics(parseCues(content))
+}
+
+// ParseVTT parses a WebVTT subtitle file into lyrics lines. "key: value" lines
+// of a "NOTE metadata" block are returned as Metadata.
+func ParseVTT(content string) ([]LyricLine, Metadata, error) {
+	if !strings.HasPrefix(strings.TrimLeft(strings.TrimPrefix(content, "\ufeff"), " \t\r\n"), "WEBVTT") {
+		return nil, nil, errors.New("missing WEBVTT header")
+	}
+
+	lyrics, err := cuesToLyrics(parseCues(content))
+	return lyrics, parseVTTMetadata(content), err
+}
+
+func parseVTTMetadata(content string) Metadata {
+	metadata := make(Metadata)
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	for block := range strings.SplitSeq(content, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if strings.TrimSpace(lines[0]) != "NOTE metadata" {
+			continue
+		}
+		for _, line := range lines[1:] {
+			if key, value, ok := parseTag(line); ok {
+				metadata[key] = value
+			}
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSRT(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []LyricLine
+		wantErr bool
+	}{
+		{
+			name:    "Empty file",
+			file:    "",
+			wantErr: true,
+		},
+		{
+			name: "Cues with gaps and multiple lines",
+			file: "1\r\n00:00:01,000 --> 00:00:03,500\r\nFirst line\r\n\r\n" +
+				"2\r\n00:00:05,000 --> 00:00:07,000\r\n<i>Second</i>\r\nline\r\n",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "First line"},
+				{Timestamp: 3*time.Second + 500*time.Millisecond},
+				{Timestamp: 5 * time.Second, Text: "Second line"},
+				{Timestamp: 7 * time.Second},
+			},
+		},
+		{
+			name: "Back to back cues",
+			file: "1\n00:00:01,000 --> 00:00:02,000\nOne\n\n2\n00:00:02,000 --> 00:00:03,000\nTwo\n",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "One"},
+				{Timestamp: 2 * time.Second, Text: "Two"},
+				{Timestamp: 3 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := ParseSRT(tt.file)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseSRT() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseSRT() succeeded unexpectedly")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSRT() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVTT(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []LyricLine
+		wantErr bool
+	}{
+		{
+			name:    "Missing header",
+			file:    "00:01.000 --> 00:02.000\nHello",
+			wantErr: true,
+		},
+		{
+			name: "Styling tags, notes and settings",
+			file: "WEBVTT - Lyrics\n\nNOTE made by hand\n\nSTYLE\n::cue { color: red }\n\n" +
+				"intro\n00:01.000 --> 00:02.000 align:start position:10%\n<v Singer><c.yellow>Rock</c> &amp; Roll</v>\n\n" +
+				"00:00:02.000 --> 00:00:04.000\n<b>I &lt;3 you</b>\n",
+			want: []LyricLine{
+				{Timestamp: 1 * time.Second, Text: "Rock & Roll", Voice: "Singer"},
+				{Timestamp: 2 * time.Second, Text: "I <3 you"},
+				{Timestamp: 4 * time.Second},
+			},
+		},
+		{
+			name: "Inline timestamps as words",
+			file: "WEBVTT\n\n00:01.000 --> 00:02.000\nHello <00:01.500>world\n",
+			want: []LyricLine{
+				{
+					Timestamp: 1 * time.Second,
+					Text:      "Hello world",
+					Words: []LyricWord{
+						{Timestamp: 1 * time.Second, Text: "Hello "},
+						{Timestamp: 1*time.Second + 500*time.Millisecond, Text: "world"},
+					},
+				},
+				{Timestamp: 2 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, gotErr := ParseVTT(tt.file)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseVTT() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseVTT() succeeded unexpectedly")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseVTT() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectLyricsFormat(t *testing.T) {
+	tests := []struct {
+		file string
+		want LyricsFormat
+	}{
+		{file: "[00:01.00]Hello", want: FormatLRC},
+		{file: "[ar:Queen]\n[00:01.00]Hello", want: FormatLRC},
+		{file: "\ufeffWEBVTT\n\n00:01.000 --> 00:02.000\nHello", want: FormatVTT},
+		{file: "1\n00:00:01,000 --> 00:00:02,000\nHello", want: FormatSRT},
+		{file: "plain text", want: FormatLRC},
+	}
+
+	for _, tt := range tests {
+		if got := DetectLyricsFormat(tt.file); got != tt.want {
+			t.Errorf("DetectLyricsFormat(%q) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// AudioExtensions are the file extensions ReadAudioTags understands
+var AudioExtensions = []string{".mp3", ".flac", ".ogg", ".oga", ".opus", ".m4a", ".mp4"}
+
+// IsAudioFile reports whether path has one of the AudioExtensions
+func IsAudioFile(path string) bool {
+	return slices.Contains(AudioExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// ReadAudioTags reads artist, title, album and duration from an audio file.
+// ID3 (mp3), FLAC, Ogg Vorbis/Opus and MP4 tags are supported.
+func ReadAudioTags(path string) (*PlayerInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var info *PlayerInfo
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		info, err = readID3(file)
+	case ".flac":
+		info, err = readFLAC(file)
+	case ".ogg", ".oga", ".opus":
+		info, err = readOgg(file)
+	case ".m4a", ".mp4":
+		info, err = readMP4(file)
+	default:
+		return nil, fmt.Errorf("unsupported audio file: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Artist == "" || info.Title == "" {
+		return nil, errors.New("missing artist or title tag")
+	}
+
+	info.ID = StringToMD5(info.Artist + info.Title)
+	return info, nil
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func decodeID3Text(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	enc, b := b[0], b[1:]
+	var s string
+	switch enc {
+	case 1, 2:
+		bigEndian := enc == 2
+		if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+			bigEndian, b = true, b[2:]
+		} else if len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe {
+			bigEndian, b = false, b[2:]
+		}
+		u := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			if bigEndian {
+				u = append(u, binary.BigEndian.Uint16(b[i:]))
+			} else {
+				u = append(u, binary.LittleEndian.Uint16(b[i:]))
+			}
+		}
+		s = string(utf16.Decode(u))
+	case 3:
+		s = string(b)
+	default:
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		s = string(r)
+	}
+
+	// Multiple values are separated by NUL, only the first one is used
+	s, _, _ = strings.Cut(s, "\x00")
+	return strings.TrimSpace(s)
+}
+
+func readID3(r io.ReadSeeker) (*PlayerInfo, error) {
+	info := &PlayerInfo{}
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err == nil && string(header[:3]) == "ID3" {
+		major := header[3]
+		size := syncsafe(header[6:10])
+
+		tag := make([]byte, size)
+		if _, err := io.ReadFull(r, tag); err != nil {
+			return nil, fmt.Errorf("failed to read ID3 tag: %w", err)
+		}
+
+		if header[5]&0x40 != 0 && len(tag) >= 4 {
+			skip := int(binary.BigEndian.Uint32(tag))
+			if major == 3 {
+				skip += 4
+			} else {
+				skip = syncsafe(tag)
+			}
+			tag = tag[min(skip, len(tag)):]
+		}
+
+		idLen, headLen := 4, 10
+		if major == 2 {
+			idLen, headLen = 3, 6
+		}
+
+		for len(tag) >= headLen && tag[0] != 0 {
+			id := string(tag[:idLen])
+
+			var frameSize int
+			switch major {
+			case 2:
+				frameSize = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+			case 3:
+				frameSize = int(binary.BigEndian.Uint32(tag[4:8]))
+			default:
+				frameSize = syncsafe(tag[4:8])
+			}
+
+			tag = tag[headLen:]
+			if frameSize > len(tag) {
+				break
+			}
+			value := tag[:frameSize]
+			tag = tag[frameSize:]
+
+			switch id {
+			case "TIT2", "TT2":
+				info.Title = decodeID3Text(value)
+			case "TPE1", "TP1":
+				info.Artist = decodeID3Text(value)
+			case "TALB", "TAL":
+				info.Album = decodeID3Text(value)
+			case "TLEN", "TLE":
+				if ms, err := strconv.Atoi(decodeID3Text(value)); err == nil {
+					info.Length = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+	}
+
+	if info.Artist != "" && info.Title != "" {
+		return info, nil
+	}
+
+	// Fallback to ID3v1 at the end of the file
+	v1 := make([]byte, 128)
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return info, nil
+	}
+	if _, err := io.ReadFull(r, v1); err != nil || string(v1[:3]) != "TAG" {
+		return info, nil
+	}
+
+	field := func(b []byte) string {
+		return strings.TrimSpace(string(bytes.TrimRight(b, "\x00 ")))
+	}
+	if info.Title == "" {
+		info.Title = field(v1[3:33])
+	}
+	if info.Artist == "" {
+		info.Artist = field(v1[33:63])
+	}
+	if info.Album == "" {
+		info.Album = field(v1[63:93])
+	}
+
+	return info, nil
+}
+
+// parseVorbisComments parses a vorbis comment block (shared by FLAC and Ogg)
+func parseVorbisComments(b []byte, info *PlayerInfo) error {
+	if len(b) < 4 {
+		return errors.New("vorbis comment is too short")
+	}
+	vendorLen := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	if vendorLen+4 > len(b) {
+		return errors.New("invalid vorbis comment vendor length")
+	}
+	b = b[vendorLen:]
+
+	count := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+
+	for range count {
+		if len(b) < 4 {
+			break
+		}
+		n := int(binary.LittleEndian.Uint32(b))
+		b = b[4:]
+		if n > len(b) {
+			break
+		}
+
+		key, value, ok := strings.Cut(string(b[:n]), "=")
+		b = b[n:]
+		if !ok {
+			continue
+		}
+
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			if info.Title == "" {
+				info.Title = strings.TrimSpace(value)
+			}
+		case "ARTIST":
+			if info.Artist == "" {
+				info.Artist = strings.TrimSpace(value)
+			}
+		case "ALBUM":
+			if info.Album == "" {
+				info.Album = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	return nil
+}
+
+func readFLAC(r io.Reader) (*PlayerInfo, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != "fLaC" {
+		return nil, errors.New("not a FLAC file")
+	}
+
+	info := &PlayerInfo{}
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("failed to read FLAC metadata: %w", err)
+		}
+
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		block := make([]byte, size)
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, fmt.Errorf("failed to read FLAC metadata: %w", err)
+		}
+
+		switch blockType {
+		case 0: // STREAMINFO
+			if len(block) >= 18 {
+				rate := uint64(block[10])<<12 | uint64(block[11])<<4 | uint64(block[12])>>4
+				samples := uint64(block[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(block[14:18]))
+				if rate > 0 {
+					info.Length = time.Duration(samples) * time.Second / time.Duration(rate)
+				}
+			}
+		case 4: // VORBIS_COMMENT
+			if err := parseVorbisComments(block, info); err != nil {
+				return nil, err
+			}
+		}
+
+		if last {
+			return info, nil
+		}
+	}
+}
+
+func readOgg(r io.Reader) (*PlayerInfo, error) {
+	// The comment header is always in the first few pages
+	head := make([]byte, 64*1024)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	info := &PlayerInfo{}
+	for _, marker := range []string{"\x03vorbis", "OpusTags"} {
+		if i := bytes.Index(head, []byte(marker)); i >= 0 {
+			err := parseVorbisComments(head[i+len(marker):], info)
+			return info, err
+		}
+	}
+
+	return nil, errors.New("failed to find ogg comment header")
+}
+
+// walkMP4 calls fn for every atom in b, descending into container atoms
+func walkMP4(b []byte, path string, fn func(path string, data []byte)) {
+	for len(b) >= 8 {
+		size := int(binary.BigEndian.Uint32(b))
+		name := string(b[4:8])
+		headLen := 8
+
+		if size == 1 && len(b) >= 16 {
+			size = int(binary.BigEndian.Uint64(b[8:16]))
+			headLen = 16
+		} else if size == 0 {
+			size = len(b)
+		}
+		if size < headLen || size > len(b) {
+			return
+		}
+
+		data := b[headLen:size]
+		b = b[size:]
+
+		p := path + "/" + name
+		fn(p, data)
+
+		switch name {
+		case "moov", "udta", "ilst", "trak", "mdia":
+			walkMP4(data, p, fn)
+		case "meta":
+			if len(data) >= 4 {
+				walkMP4(data[4:], p, fn)
+			}
+		}
+	}
+}
+
+func readMP4(r io.Reader) (*PlayerInfo, error) {
+	// moov may be at the end of the file, so the whole file has to be read.
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &PlayerInfo{}
+	value := func(data []byte) string {
+		// data atom: size(4) "data" type(4) locale(4) value
+		if len(data) < 16 || string(data[4:8]) != "data" {
+			return ""
+		}
+		return strings.TrimSpace(string(data[16:]))
+	}
+
+	walkMP4(b, "", func(path string, data []byte) {
+		switch path {
+		case "/moov/udta/meta/ilst/\xa9nam":
+			info.Title = value(data)
+		case "/moov/udta/meta/ilst/\xa9ART":
+			info.Artist = value(data)
+		case "/moov/udta/meta/ilst/\xa9alb":
+			info.Album = value(data)
+		case "/moov/mvhd":
+			if len(data) >= 20 && data[0] == 0 {
+				scale := binary.BigEndian.Uint32(data[12:16])
+				duration := binary.BigEndian.Uint32(data[16:20])
+				if scale > 0 {
+					info.Length = time.Duration(duration) * time.Second / time.Duration(scale)
+				}
+			} else if len(data) >= 32 && data[0] == 1 {
+				scale := binary.BigEndian.Uint32(data[20:24])
+				duration := binary.BigEndian.Uint64(data[24:32])
+				if scale > 0 {
+					info.Length = time.Duration(duration) * time.Second / time.Duration(scale)
+				}
+			}
+		}
+	})
+
+	return info, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Nadim147c/go-mpris"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	mprisPath         = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	propertiesChanged = "org.freedesktop.DBus.Properties.PropertiesChanged"
+	nameOwnerChanged  = "org.freedesktop.DBus.NameOwnerChanged"
+	seekedSignal      = mpris.PlayerInterface + ".Seeked"
+)
+
+// ErrPlayerGone is returned by Tracker.Info when the player left the bus
+var ErrPlayerGone = errors.New("player is gone")
+
+// Tracker follows a player with its D-Bus signals instead of polling it. The
+// metadata and the status are taken from PropertiesChanged and the position is
+// extrapolated from the last known position with the playback rate. The
+// player is only asked for the position when it seeks, its status changes or
+// on the periodic drift check.
+type Tracker struct {
+	conn   *dbus.Conn
+	player *mpris.Player
+
+	mu sync.Mutex
+	// owner is the unique bus name of the player, signals are sent by it
+	owner    string
+	gone     bool
+	metadata map[string]dbus.Variant
+	status   mpris.PlaybackStatus
+	rate     float64
+	// position of the player at anchor. The monotonic clock of anchor is used
+	// for the extrapolation.
+	position time.Duration
+	anchor   time.Time
+
+	// getAll loads every property of the player
+	getAll func() (map[string]dbus.Variant, error)
+}
+
+// activeTracker is the tracker of the main loop
+var activeTracker atomic.Pointer[Tracker]
+
+// PlayerPosition returns the position of the followed player without asking
+// it
+func PlayerPosition() time.Duration {
+	if t := activeTracker.Load(); t != nil {
+		return t.Position()
+	}
+	return 0
+}
+
+// NewTracker watches the signals of player and loads its properties
+func NewTracker(conn *dbus.Conn, player *mpris.Player) *Tracker {
+	t := &Tracker{conn: conn, player: player, rate: 1}
+	t.getAll = t.callGetAll
+	for _, rule := range t.matchRules() {
+		if err := conn.AddMatchSignal(rule...); err != nil {
+			slog.Warn("Failed to watch player signals", "error", err)
+		}
+	}
+
+	// Only the bus decides whether the player is gone, properties which fail
+	// to load are retried by Check
+	err := conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, player.GetName()).Store(&t.owner)
+	if err != nil {
+		slog.Debug("Player is not available", "name", player.GetName(), "error", err)
+		t.gone = true
+		return t
+	}
+	if err := t.Refresh(); err != nil {
+		slog.Error("Failed to load player properties", "error", err)
+	}
+	return t
+}
+
+// matchRules are the signals of the player and its bus name
+func (t *Tracker) matchRules() [][]dbus.MatchOption {
+	name := t.player.GetName()
+	return [][]dbus.MatchOption{
+		{dbus.WithMatchSender(name), dbus.WithMatchObjectPath(mprisPath)},
+		{
+			dbus.WithMatchSender("org.freedesktop.DBus"),
+			dbus.WithMatchMember("NameOwnerChanged"),
+			dbus.WithMatchArg(0, name),
+		},
+	}
+}
+
+// Close stops watching the signals of the player
+func (t *Tracker) Close() {
+	for _, rule := range t.matchRules() {
+		t.conn.RemoveMatchSignal(rule...)
+	}
+}
+
+// anchorAt sets the position of the player now
+func (t *Tracker) anchorAt(position time.Duration) {
+	t.position, t.anchor = position, time.Now()
+}
+
+// setRate changes the playback rate without moving the extrapolated position
+func (t *Tracker) setRate(rate float64) {
+	// The position doesn't move at a rate of 0, which isn't allowed while
+	// playing
+	if rate <= 0 {
+		rate = 1
+	}
+	t.anchorAt(t.positionLocked())
+	t.rate = rate
+}
+
+// callGetAll loads every property of the player with a single GetAll call
+func (t *Tracker) callGetAll() (map[string]dbus.Variant, error) {
+	var props map[string]dbus.Variant
+	err := t.conn.Object(t.player.GetName(), mprisPath).
+		Call("org.freedesktop.DBus.Properties.GetAll", 0, mpris.PlayerInterface).
+		Store(&props)
+	return props, err
+}
+
+// Refresh loads every property of the player
+func (t *Tracker) Refresh() error {
+	props, err := t.getAll()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	position, hasPosition := props["Position"].Value().(int64)
+	if hasPosition && t.status == mpris.PlaybackPlaying {
+		drift := time.Duration(position)*time.Microsecond - t.positionLocked()
+		slog.Debug("Position drift", "drift", drift.String())
+	}
+
+	t.apply(props)
+	if hasPosition {
+		t.anchorAt(time.Duration(position) * time.Microsecond)
+	}
+	return nil
+}
+
+// apply updates the tracker with changed properties of the player
+func (t *Tracker) apply(props map[string]dbus.Variant) {
+	if v, ok := props["Metadata"].Value().(map[string]dbus.Variant); ok {
+		t.metadata = v
+	}
+	if v, ok := props["PlaybackStatus"].Value().(string); ok {
+		t.anchorAt(t.positionLocked())
+		t.status = mpris.PlaybackStatus(v)
+	}
+	if v, ok := props["Rate"].Value().(float64); ok {
+		t.setRate(v)
+	}
+}
+
+// syncPosition asks the player for the position
+func (t *Tracker) syncPosition() error {
+	position, err := t.player.GetPosition()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.anchorAt(position)
+	return nil
+}
+
+// Handle updates the tracker with a signal. It returns false when the signal
+// isn't about the player.
+func (t *Tracker) Handle(sig *dbus.Signal) bool {
+	if sig.Name == nameOwnerChanged {
+		if len(sig.Body) != 3 || sig.Body[0] != t.player.GetName() {
+			return false
+		}
+		owner, _ := sig.Body[2].(string)
+
+		t.mu.Lock()
+		t.owner = owner
+		t.gone = owner == ""
+		t.mu.Unlock()
+
+		if owner != "" {
+			if err := t.Refresh(); err != nil {
+				slog.Error("Failed to load player properties", "error", err)
+			}
+		}
+		return true
+	}
+
+	t.mu.Lock()
+	owner := t.owner
+	t.mu.Unlock()
+	if sig.Sender != owner || sig.Path != mprisPath {
+		return false
+	}
+
+	switch sig.Name {
+	case propertiesChanged:
+		if len(sig.Body) != 3 || sig.Body[0] != mpris.PlayerInterface {
+			return false
+		}
+		changed, _ := sig.Body[1].(map[string]dbus.Variant)
+		invalidated, _ := sig.Body[2].([]string)
+
+		// Properties which are invalidated must be asked for
+		if len(invalidated) != 0 {
+			if err := t.Refresh(); err != nil {
+				slog.Error("Failed to load player properties", "error", err)
+			}
+			return true
+		}
+
+		t.mu.Lock()
+		t.apply(changed)
+		position, hasPosition := changed["Position"].Value().(int64)
+		if hasPosition {
+			t.anchorAt(time.Duration(position) * time.Microsecond)
+		}
+		t.mu.Unlock()
+
+		// Players often reset or jump the position with a new track or status
+		needsPosition := slices.ContainsFunc([]string{"Metadata", "PlaybackStatus"}, func(key string) bool {
+			_, ok := changed[key]
+			return ok
+		})
+		if needsPosition && !hasPosition {
+			if err := t.syncPosition(); err != nil {
+				slog.Error("Failed to get player position", "error", err)
+			}
+		}
+		return true
+	case seekedSignal:
+		if err := t.syncPosition(); err != nil {
+			slog.Error("Failed to get player position", "error", err)
+		}
+		return true
+	}
+	return false
+}
+
+// Check corrects the drift of the extrapolated position and picks up changes
+// which the player didn't signal. Properties which failed to load are loaded
+// again.
+func (t *Tracker) Check() {
+	t.mu.Lock()
+	gone := t.gone
+	t.mu.Unlock()
+	if gone {
+		return
+	}
+
+	if err := t.Refresh(); err != nil {
+		slog.Error("Failed to load player properties", "error", err)
+	}
+}
+
+// positionLocked returns the extrapolated position, t.mu must be held
+func (t *Tracker) positionLocked() time.Duration {
+	position := t.position
+	if t.status == mpris.PlaybackPlaying {
+		position += time.Duration(float64(time.Since(t.anchor)) * t.rate)
+	}
+	if length := metadataLength(t.metadata); length > 0 {
+		position = min(position, length)
+	}
+	return max(position, 0)
+}
+
+// Position returns the extrapolated position of the player
+func (t *Tracker) Position() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.positionLocked()
+}
+
+// WallTime returns how long it takes to play d of the track at the playback
+// rate
+func (t *Tracker) WallTime(d time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Duration(float64(d) / t.rate)
+}
+
+// Info returns the track of the player with the extrapolated position
+func (t *Tracker) Info() (*PlayerInfo, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.gone {
+		return nil, ErrPlayerGone
+	}
+	return newPlayerInfo(t.metadata, t.status, t.positionLocked())
+}
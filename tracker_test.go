@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Nadim147c/go-mpris"
+	"github.com/godbus/dbus/v5"
+)
+
+func newTestTracker() *Tracker {
+	return &Tracker{
+		player: &mpris.Player{},
+		owner:  ":1.5",
+		rate:   1,
+		metadata: map[string]dbus.Variant{
+			"xesam:title":  dbus.MakeVariant("Title"),
+			"xesam:artist": dbus.MakeVariant([]string{"Artist"}),
+			"mpris:length": dbus.MakeVariant(int64(60_000_000)),
+		},
+		status: mpris.PlaybackPlaying,
+	}
+}
+
+// near reports whether got is within 50ms of want
+func near(got, want time.Duration) bool {
+	return got >= want-50*time.Millisecond && got <= want+50*time.Millisecond
+}
+
+func TestTrackerPosition(t *testing.T) {
+	tr := newTestTracker()
+	tr.position, tr.anchor = 10*time.Second, time.Now().Add(-time.Second)
+	if got := tr.Position(); !near(got, 11*time.Second) {
+		t.Errorf("Position() while playing = %s, want 11s", got)
+	}
+
+	tr.setRate(2)
+	tr.anchor = tr.anchor.Add(-time.Second)
+	if got := tr.Position(); !near(got, 13*time.Second) {
+		t.Errorf("Position() at rate 2 = %s, want 13s", got)
+	}
+	if got := tr.WallTime(time.Second); got != 500*time.Millisecond {
+		t.Errorf("WallTime(1s) at rate 2 = %s, want 500ms", got)
+	}
+
+	tr.status = mpris.PlaybackPaused
+	if got := tr.Position(); got != tr.position {
+		t.Errorf("Position() while paused = %s, want %s", got, tr.position)
+	}
+
+	// The position doesn't pass the end of the track
+	tr.status = mpris.PlaybackPlaying
+	tr.position = 2 * time.Minute
+	if got := tr.Position(); got != time.Minute {
+		t.Errorf("Position() after the end = %s, want 1m", got)
+	}
+}
+
+func TestTrackerHandle(t *testing.T) {
+	tr := newTestTracker()
+	tr.anchorAt(30 * time.Second)
+
+	changed := &dbus.Signal{
+		Sender: ":1.5",
+		Path:   mprisPath,
+		Name:   propertiesChanged,
+		Body: []any{mpris.PlayerInterface, map[string]dbus.Variant{
+			"PlaybackStatus": dbus.MakeVariant("Paused"),
+			"Position":       dbus.MakeVariant(int64(5_000_000)),
+		}, []string{}},
+	}
+	if !tr.Handle(changed) {
+		t.Fatal("Handle() of the player's PropertiesChanged = false")
+	}
+	info, err := tr.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != mpris.PlaybackPaused || info.Position != 5*time.Second || info.Length != time.Minute {
+		t.Errorf("Info() = %+v", info)
+	}
+
+	// Signals of other players are ignored
+	changed.Sender = ":1.9"
+	if tr.Handle(changed) {
+		t.Error("Handle() of another sender = true")
+	}
+
+	gone := &dbus.Signal{
+		Sender: "org.freedesktop.DBus",
+		Name:   nameOwnerChanged,
+		Body:   []any{tr.player.GetName(), ":1.5", ""},
+	}
+	if !tr.Handle(gone) {
+		t.Fatal("Handle() of NameOwnerChanged = false")
+	}
+	if _, err := tr.Info(); !errors.Is(err, ErrPlayerGone) {
+		t.Errorf("Info() of a gone player error = %v, want ErrPlayerGone", err)
+	}
+}
+
+func TestTrackerCheck(t *testing.T) {
+	tr := newTestTracker()
+	calls := 0
+	tr.getAll = func() (map[string]dbus.Variant, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("timeout")
+		}
+		return map[string]dbus.Variant{
+			"Metadata": dbus.MakeVariant(map[string]dbus.Variant{
+				"xesam:title":  dbus.MakeVariant("New Title"),
+				"xesam:artist": dbus.MakeVariant([]string{"Artist"}),
+			}),
+			"Position": dbus.MakeVariant(int64(0)),
+		}, nil
+	}
+
+	// Failed refreshes don't make the player gone and are retried
+	for range 2 {
+		tr.Check()
+		if _, err := tr.Info(); err != nil {
+			t.Fatalf("Info() after a failed refresh error = %v", err)
+		}
+	}
+
+	tr.Check()
+	info, err := tr.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || info.Title != "New Title" {
+		t.Errorf("Info() after %d refreshes = %+v, want the new title", calls, info)
+	}
+}
+
+func TestMetadataLength(t *testing.T) {
+	tests := []struct {
+		value any
+		want  time.Duration
+	}{
+		{int64(90_000_000), 90 * time.Second},
+		{uint64(90_000_000), 90 * time.Second},
+		{"90", 0},
+	}
+	for _, tt := range tests {
+		meta := map[string]dbus.Variant{"mpris:length": dbus.MakeVariant(tt.value)}
+		if got := metadataLength(meta); got != tt.want {
+			t.Errorf("metadataLength(%T) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+	if got := metadataLength(nil); got != 0 {
+		t.Errorf("metadataLength(nil) = %s, want 0", got)
+	}
+}
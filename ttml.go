@@ -0,0 +1,243 @@
+package main
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ParseTTMLTime parses a TTML time expression. Clock times ("1:23.456",
+// "00:01:23.456") and offset times ("12.3s", "450ms", "2m", "1h") are
+// supported.
+func ParseTTMLTime(ts string) (time.Duration, error) {
+	ts = strings.TrimSpace(ts)
+
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"ms", time.Millisecond},
+		{"s", time.Second},
+		{"m", time.Minute},
+		{"h", time.Hour},
+	}
+
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(ts, u.suffix); ok {
+			value, err := strconv.ParseFloat(num, 64)
+			if err != nil || value < 0 {
+				return 0, fmt.Errorf("invalid TTML time: %s", ts)
+			}
+			return time.Duration(value * float64(u.unit)), nil
+		}
+	}
+
+	return ParseTimestamp(ts)
+}
+
+// ttmlLine is a <p> element of a TTML document
+type ttmlLine struct {
+	line LyricLine
+	end  time.Duration
+}
+
+// ttmlSpan is an open <span> element
+type ttmlSpan struct {
+	begin time.Duration
+	timed bool
+	bg    bool
+	// used is set once the span text started a word
+	used bool
+}
+
+func ttmlAttr(el xml.StartElement, name string) (string, bool) {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func attrOr(el xml.StartElement, name, fallback string) string {
+	if value, ok := ttmlAttr(el, name); ok {
+		return value
+	}
+	return fallback
+}
+
+// joinWords trims the spaces around words and returns the text of the line
+func joinWords(words []LyricWord) string {
+	if len(words) == 0 {
+		return ""
+	}
+	words[0].Text = strings.TrimLeftFunc(words[0].Text, unicode.IsSpace)
+	words[len(words)-1].Text = strings.TrimRightFunc(words[len(words)-1].Text, unicode.IsSpace)
+
+	var text strings.Builder
+	for _, word := range words {
+		text.WriteString(word.Text)
+	}
+	return text.String()
+}
+
+// ParseTTML parses a TTML (Apple Music style) lyrics document. Every <p> becomes
+// a LyricLine and timed spans become word timings. The ttm:agent of a line is
+// stored as its Voice and spans with ttm:role="x-bg" are stored as the
+// Background line.
+func ParseTTML(content string) ([]LyricLine, Metadata, error) {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+
+	metadata := make(Metadata)
+
+	var lines []ttmlLine
+	var current *ttmlLine
+	var spans []ttmlSpan
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, metadata, fmt.Errorf("failed to parse TTML: %w", err)
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "tt":
+				if lang, ok := ttmlAttr(el, "lang"); ok {
+					metadata["la"] = lang
+				}
+			case "p":
+				begin, err := ParseTTMLTime(attrOr(el, "begin", "0"))
+				if err != nil {
+					continue
+				}
+				end, _ := ParseTTMLTime(attrOr(el, "end", "0"))
+
+				current = &ttmlLine{line: LyricLine{Timestamp: begin}, end: end}
+				current.line.Voice, _ = ttmlAttr(el, "agent")
+				spans = spans[:0]
+			case "span":
+				if current == nil {
+					continue
+				}
+
+				span := ttmlSpan{begin: current.line.Timestamp}
+				if len(spans) > 0 {
+					outer := spans[len(spans)-1]
+					span.begin, span.bg = outer.begin, outer.bg
+				}
+				if role, _ := ttmlAttr(el, "role"); role == "x-bg" {
+					span.bg = true
+				}
+				if begin, ok := ttmlAttr(el, "begin"); ok {
+					if timestamp, err := ParseTTMLTime(begin); err == nil {
+						span.begin, span.timed = timestamp, true
+					}
+				}
+				spans = append(spans, span)
+			}
+
+		case xml.EndElement:
+			switch el.Name.Local {
+			case "p":
+				if current != nil {
+					lines = append(lines, *current)
+					current = nil
+				}
+			case "span":
+				if len(spans) > 0 {
+					spans = spans[:len(spans)-1]
+				}
+			}
+
+		case xml.CharData:
+			if current == nil {
+				continue
+			}
+
+			text := string(el)
+			line := &current.line
+			var span *ttmlSpan
+			if len(spans) > 0 {
+				span = &spans[len(spans)-1]
+				if span.bg {
+					if line.Background == nil {
+						line.Background = &LyricLine{Timestamp: span.begin}
+					}
+					line = line.Background
+				}
+			}
+
+			// Indentation and line breaks between spans separate words
+			if strings.TrimSpace(text) == "" {
+				if len(line.Words) == 0 {
+					continue
+				}
+				text = " "
+			}
+
+			timestamp := current.line.Timestamp
+			startWord := false
+			if span != nil {
+				timestamp = span.begin
+				startWord = span.timed && !span.used
+				span.used = true
+			}
+
+			if startWord || len(line.Words) == 0 {
+				line.Words = append(line.Words, LyricWord{Timestamp: timestamp, Text: text})
+			} else {
+				line.Words[len(line.Words)-1].Text += text
+			}
+		}
+	}
+
+	var lyrics []LyricLine
+	for i, l := range lines {
+		line := l.line
+		line.Text = joinWords(line.Words)
+
+		// Lines without timed spans are only line-synced
+		if len(line.Words) <= 1 {
+			line.Words = nil
+		}
+
+		if bg := line.Background; bg != nil {
+			bg.Text = joinWords(bg.Words)
+			if len(bg.Words) <= 1 {
+				bg.Words = nil
+			}
+			if bg.Text == "" {
+				line.Background = nil
+			}
+		}
+
+		lyrics = append(lyrics, line)
+
+		if l.end > line.Timestamp && (i+1 == len(lines) || l.end < lines[i+1].line.Timestamp) {
+			lyrics = append(lyrics, LyricLine{Timestamp: l.end})
+		}
+	}
+
+	if len(lyrics) == 0 {
+		return lyrics, metadata, errors.New("Lyric lines are 0")
+	}
+
+	slices.SortStableFunc(lyrics, func(a, b LyricLine) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+
+	return lyrics, metadata, nil
+}
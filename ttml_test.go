@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const sampleTTML = `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xml:lang="en">
+  <head>
+    <metadata>
+      <ttm:agent type="person" xml:id="v1"/>
+      <ttm:agent type="person" xml:id="v2"/>
+    </metadata>
+  </head>
+  <body dur="20.000">
+    <div begin="1.000" end="9.000">
+      <p begin="1.000" end="3.000" ttm:agent="v1"><span begin="1.000" end="1.400">Won</span><span begin="1.400" end="1.800">der</span> <span begin="1.800" end="2.500">wall</span><span ttm:role="x-bg"><span begin="2.500" end="2.800">(oh</span> <span begin="2.800" end="3.000">yeah)</span></span></p>
+      <p begin="00:00:05.5" end="00:00:07" ttm:agent="v2">Line &amp; synced</p>
+      <p begin="7s" end="9s" ttm:agent="v1">
+        <span begin="7s" end="8s">Pretty</span>
+        <span begin="8s" end="9s">printed</span>
+      </p>
+    </div>
+  </body>
+</tt>`
+
+func TestParseTTML(t *testing.T) {
+	got, meta, err := ParseTTML(sampleTTML)
+	if err != nil {
+		t.Fatalf("ParseTTML() failed: %v", err)
+	}
+
+	want := []LyricLine{
+		{
+			Timestamp: 1 * time.Second,
+			Text:      "Wonder wall",
+			Voice:     "v1",
+			Words: []LyricWord{
+				{Timestamp: 1 * time.Second, Text: "Won"},
+				{Timestamp: 1400 * time.Millisecond, Text: "der "},
+				{Timestamp: 1800 * time.Millisecond, Text: "wall"},
+			},
+			Background: &LyricLine{
+				Timestamp: 2500 * time.Millisecond,
+				Text:      "(oh yeah)",
+				Words: []LyricWord{
+					{Timestamp: 2500 * time.Millisecond, Text: "(oh "},
+					{Timestamp: 2800 * time.Millisecond, Text: "yeah)"},
+				},
+			},
+		},
+		{Timestamp: 3 * time.Second},
+		{Timestamp: 5500 * time.Millisecond, Text: "Line & synced", Voice: "v2"},
+		{
+			Timestamp: 7 * time.Second,
+			Text:      "Pretty printed",
+			Voice:     "v1",
+			Words: []LyricWord{
+				{Timestamp: 7 * time.Second, Text: "Pretty "},
+				{Timestamp: 8 * time.Second, Text: "printed"},
+			},
+		},
+		{Timestamp: 9 * time.Second},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTTML() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if meta["la"] != "en" {
+		t.Errorf("ParseTTML() language = %q, want %q", meta["la"], "en")
+	}
+
+	if DetectLyricsFormat(sampleTTML) != FormatTTML {
+		t.Errorf("DetectLyricsFormat() didn't detect TTML")
+	}
+}
+
+func TestParseTTMLTime(t *testing.T) {
+	tests := []struct {
+		ts   string
+		want time.Duration
+	}{
+		{ts: "12.5s", want: 12*time.Second + 500*time.Millisecond},
+		{ts: "450ms", want: 450 * time.Millisecond},
+		{ts: "2m", want: 2 * time.Minute},
+		{ts: "1:02.250", want: time.Minute + 2*time.Second + 250*time.Millisecond},
+		{ts: "00:01:02.250", want: time.Minute + 2*time.Second + 250*time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTTMLTime(tt.ts)
+		if err != nil {
+			t.Errorf("ParseTTMLTime(%q) failed: %v", tt.ts, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTTMLTime(%q) = %v, want %v", tt.ts, got, tt.want)
+		}
+	}
+}
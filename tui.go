@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Nadim147c/go-mpris"
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// tuiFrame is the time between frames of the TUI
+	tuiFrame = time.Second / 30
+	// tuiScrollSpeed is the part of the distance to the current line scrolled
+	// every frame
+	tuiScrollSpeed = 0.25
+	// tuiOffsetStep is the offset added by the offset keys
+	tuiOffsetStep = 100 * time.Millisecond
+	// tuiMessageTime is how long messages are shown in the footer
+	tuiMessageTime = 2 * time.Second
+)
+
+// Escape sequences of the terminal
+const (
+	ansiReset       = "\x1b[0m"
+	ansiBold        = "\x1b[1m"
+	ansiDim         = "\x1b[2m"
+	ansiReverse     = "\x1b[7m"
+	ansiClearLine   = "\x1b[2K"
+	ansiAltScreen   = "\x1b[?1049h"
+	ansiMainScreen  = "\x1b[?1049l"
+	ansiHideCursor  = "\x1b[?25l"
+	ansiShowCursor  = "\x1b[?25h"
+	tuiHelp         = "space play/pause  ↑↓ select  enter seek  +/- offset  r refetch  c current  q quit"
+	tuiPlaceholder  = "♪"
+	tuiDefaultColor = "\x1b[32m"
+)
+
+// ansiColor returns the escape sequence of a #rrggbb foreground color
+func ansiColor(hex string) string {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return tuiDefaultColor
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return tuiDefaultColor
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", rgb>>16, (rgb>>8)&0xff, rgb&0xff)
+}
+
+// parseKeys returns the names of the keys in input read from the terminal.
+// Keys without a name are returned as they are.
+func parseKeys(input []byte) []string {
+	var keys []string
+	for len(input) > 0 {
+		switch {
+		case string(input) == "\x1b":
+			return append(keys, "esc")
+		case strings.HasPrefix(string(input), "\x1b[A"), strings.HasPrefix(string(input), "\x1bOA"):
+			keys, input = append(keys, "up"), input[3:]
+		case strings.HasPrefix(string(input), "\x1b[B"), strings.HasPrefix(string(input), "\x1bOB"):
+			keys, input = append(keys, "down"), input[3:]
+		case strings.HasPrefix(string(input), "\x1b[5~"):
+			keys, input = append(keys, "pgup"), input[4:]
+		case strings.HasPrefix(string(input), "\x1b[6~"):
+			keys, input = append(keys, "pgdown"), input[4:]
+		case input[0] == '\x1b':
+			// Skip unknown escape sequences
+			end := 1
+			if len(input) > 1 && input[1] == '[' {
+				end = 2
+				for end < len(input) && (input[end] < 0x40 || input[end] > 0x7e) {
+					end++
+				}
+				end = min(end+1, len(input))
+			}
+			input = input[end:]
+		case input[0] == '\r' || input[0] == '\n':
+			keys, input = append(keys, "enter"), input[1:]
+		case input[0] == ' ':
+			keys, input = append(keys, "space"), input[1:]
+		case input[0] == 3:
+			keys, input = append(keys, "ctrl+c"), input[1:]
+		default:
+			keys, input = append(keys, string(input[:1])), input[1:]
+		}
+	}
+	return keys
+}
+
+// terminal is the state of the terminal running the TUI
+type terminal struct {
+	fd       int
+	original *unix.Termios
+}
+
+// openTerminal switches the terminal on stdin to raw mode and the alternate
+// screen
+func openTerminal() (*terminal, error) {
+	fd := int(os.Stdin.Fd())
+	original, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, errors.New("tui needs a terminal")
+	}
+
+	raw := *original
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		return nil, fmt.Errorf("failed to set raw mode: %w", err)
+	}
+
+	os.Stdout.WriteString(ansiAltScreen + ansiHideCursor)
+	return &terminal{fd: fd, original: original}, nil
+}
+
+// size returns the number of columns and rows of the terminal
+func (t *terminal) size() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// close restores the terminal
+func (t *terminal) close() {
+	os.Stdout.WriteString(ansiReset + ansiShowCursor + ansiMainScreen)
+	unix.IoctlSetTermios(t.fd, unix.TCSETS, t.original)
+}
+
+// tui is the full screen lyrics viewer
+type tui struct {
+	conn   *dbus.Conn
+	width  int
+	height int
+
+	// scroll is the line shown in the middle of the screen. It moves toward
+	// the focused line every frame.
+	scroll float64
+	// selected is the line selected with the keys, -1 to follow the current
+	// line
+	selected int
+
+	track string
+
+	message      string
+	messageUntil time.Time
+	lastFrame    string
+}
+
+// player returns the followed player
+func (t *tui) player() *mpris.Player {
+	return mpris.New(t.conn, CurrentState().Player)
+}
+
+// now returns the position of the lyrics, extrapolated by the tracker of
+// the main loop
+func (t *tui) now(s State) time.Duration {
+	return PlayerPosition() + s.Offset
+}
+
+// notify shows a message in the footer
+func (t *tui) notify(format string, args ...any) {
+	t.message = fmt.Sprintf(format, args...)
+	t.messageUntil = time.Now().Add(tuiMessageTime)
+}
+
+// focus returns the line kept in the middle of the screen, the selected line
+// or the current line
+func (t *tui) focus(lyrics Lyrics, current int) int {
+	if t.selected >= 0 && t.selected < len(lyrics) {
+		return t.selected
+	}
+	return max(current, 0)
+}
+
+// handleKey runs the action of a key and reports whether the TUI should quit
+func (t *tui) handleKey(key string) bool {
+	s := CurrentState()
+	switch key {
+	case "q", "ctrl+c":
+		return true
+	case "space", "p":
+		if err := t.player().PlayPause(); err != nil {
+			t.notify("Failed to toggle player: %v", err)
+		}
+	case "up", "k":
+		t.selected = max(t.focus(s.Lyrics, s.Index)-1, 0)
+	case "down", "j":
+		t.selected = min(t.focus(s.Lyrics, s.Index)+1, len(s.Lyrics)-1)
+	case "pgup":
+		t.selected = max(t.focus(s.Lyrics, s.Index)-t.height/2, 0)
+	case "pgdown":
+		t.selected = min(t.focus(s.Lyrics, s.Index)+t.height/2, len(s.Lyrics)-1)
+	case "c", "esc":
+		t.selected = -1
+	case "enter":
+		if t.selected < 0 || t.selected >= len(s.Lyrics) {
+			break
+		}
+		// Seek a bit into the line, so rounding doesn't show the line before
+		target := s.Lyrics[t.selected].Timestamp - s.Offset + 10*time.Millisecond
+		if err := t.player().SetPosition(max(target, 0)); err != nil {
+			t.notify("Failed to seek: %v", err)
+		}
+		// The tracker picks up the new position from the Seeked signal
+		t.selected = -1
+	case "+", "=", "-", "_":
+		if s.Track == nil {
+			t.notify("Offset needs a playing track")
+			break
+		}
+		step := tuiOffsetStep
+		if key == "-" || key == "_" {
+			step = -step
+		}
+		Commands <- Command{Offset: step}
+		t.notify("Offset %v", s.Offset+step)
+	case "r":
+		Commands <- Command{Refetch: true}
+		t.notify("Refetching lyrics")
+	}
+	return false
+}
+
+// center pads text to the middle of width cells
+func center(text string, width int) string {
+	return strings.Repeat(" ", max((width-StringWidth(text))/2, 0)) + text
+}
+
+// renderLine returns the row of line. The current line is bold with the sung
+// words colored.
+func (t *tui) renderLine(line LyricLine, current, selected bool, position time.Duration) string {
+	text := line.Text
+	if text == "" {
+		text = tuiPlaceholder
+	}
+	shown := Truncate(text, t.width-2, false)
+	padding := strings.Repeat(" ", max((t.width-StringWidth(shown))/2, 0))
+
+	var style string
+	switch {
+	case current:
+		style = ansiBold
+	case selected:
+		style = ansiReverse
+	default:
+		style = ansiDim
+	}
+
+	if !current {
+		return padding + style + shown + ansiReset
+	}
+
+	sung := 0
+	if len(line.Words) != 0 && text == line.Text {
+		sung = line.SungLength(line.WordIndex(position))
+	} else if len(line.Words) == 0 {
+		sung = len([]rune(shown))
+	}
+	runes := []rune(shown)
+	sung = min(sung, len(runes))
+
+	color := ansiColor(KaraokeColor)
+	if selected {
+		style += ansiReverse
+	}
+	return padding + style + color + string(runes[:sung]) + ansiReset + style + string(runes[sung:]) + ansiReset
+}
+
+// render draws a frame
+func (t *tui) render() {
+	s := CurrentState()
+	position := t.now(s)
+
+	// The current line by position, so it changes without waiting for the
+	// main loop
+	index := -1
+	for i, line := range s.Lyrics {
+		if position <= line.Timestamp {
+			break
+		}
+		index = i
+	}
+
+	target := float64(t.focus(s.Lyrics, index))
+	t.scroll += (target - t.scroll) * tuiScrollSpeed
+	if math.Abs(target-t.scroll) < 0.05 {
+		t.scroll = target
+	}
+
+	rows := make([]string, t.height)
+	if s.Track != nil {
+		header := fmt.Sprintf("%s - %s", s.Track.Artist, s.Track.Title)
+		rows[0] = ansiBold + center(Truncate(header, t.width, false), t.width) + ansiReset
+	}
+
+	footer := tuiHelp
+	if time.Now().Before(t.messageUntil) {
+		footer = t.message
+	}
+	rows[t.height-1] = ansiDim + center(Truncate(footer, t.width, false), t.width) + ansiReset
+
+	// Lyrics are drawn between the header and the footer
+	top, bottom := 2, t.height-2
+	middle := top + (bottom-top)/2
+	switch {
+	case s.Track == nil:
+		rows[middle] = ansiDim + center("No player", t.width) + ansiReset
+	case len(s.Lyrics) == 0:
+		rows[middle] = ansiDim + center("No lyrics", t.width) + ansiReset
+	default:
+		for i, line := range s.Lyrics {
+			row := middle + int(math.Round(float64(i)-t.scroll))
+			if row < top || row > bottom {
+				continue
+			}
+			rows[row] = t.renderLine(line, i == index, i == t.selected, position)
+		}
+	}
+
+	var frame strings.Builder
+	for i, row := range rows {
+		fmt.Fprintf(&frame, "\x1b[%d;1H%s%s", i+1, ansiClearLine, row)
+	}
+	if frame.String() == t.lastFrame {
+		return
+	}
+	t.lastFrame = frame.String()
+	os.Stdout.WriteString(t.lastFrame)
+}
+
+// RunTUI shows the lyrics of the state in the terminal until q is pressed or
+// ctx is done
+func RunTUI(ctx context.Context, conn *dbus.Conn) error {
+	term, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer term.close()
+
+	t := &tui{conn: conn, selected: -1}
+	t.width, t.height = term.size()
+
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	defer signal.Stop(resize)
+
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+
+	frame := time.NewTicker(tuiFrame)
+	defer frame.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-resize:
+			t.width, t.height = term.size()
+			t.lastFrame = ""
+		case input := <-keys:
+			for _, key := range parseKeys(input) {
+				if t.handleKey(key) {
+					return nil
+				}
+			}
+		case <-frame.C:
+		}
+
+		// A new track starts without a selection
+		if s := CurrentState(); s.Track != nil && s.Track.ID != t.track {
+			t.track = s.Track.ID
+			t.selected = -1
+		}
+
+		if t.height < 4 || t.width < 8 {
+			continue
+		}
+		t.render()
+	}
+}
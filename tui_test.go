@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "q", want: []string{"q"}},
+		{input: " \r", want: []string{"space", "enter"}},
+		{input: "\x1b[A\x1b[B", want: []string{"up", "down"}},
+		{input: "\x1b", want: []string{"esc"}},
+		{input: "\x1b[1;5Cr", want: []string{"r"}},
+		{input: "\x03", want: []string{"ctrl+c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := parseKeys([]byte(tt.input)); !slices.Equal(got, tt.want) {
+				t.Errorf("parseKeys(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderLine(t *testing.T) {
+	defer func(color string) { KaraokeColor = color }(KaraokeColor)
+	KaraokeColor = "#ff0000"
+
+	line := LyricLine{
+		Timestamp: time.Second,
+		Text:      "Hello world",
+		Words: []LyricWord{
+			{Timestamp: time.Second, Text: "Hello"},
+			{Timestamp: 2 * time.Second, Text: " world"},
+		},
+	}
+	view := &tui{width: 21}
+
+	want := "     " + ansiBold + "\x1b[38;2;255;0;0mHello" + ansiReset + ansiBold + " world" + ansiReset
+	if got := view.renderLine(line, true, false, 1500*time.Millisecond); got != want {
+		t.Errorf("renderLine() = %q, want %q", got, want)
+	}
+
+	want = "     " + ansiDim + "Hello world" + ansiReset
+	if got := view.renderLine(line, false, false, 0); got != want {
+		t.Errorf("renderLine() = %q, want %q", got, want)
+	}
+}
+
+func TestHandleKeyOffset(t *testing.T) {
+	defer UpdateState(EventState, func(s *State) { *s = State{Index: -1} })
+
+	ui := &tui{}
+	ui.handleKey("+")
+	if len(Commands) != 0 || ui.message != "Offset needs a playing track" {
+		t.Fatalf("handleKey(+) without a track = %q with %d commands", ui.message, len(Commands))
+	}
+
+	UpdateState(EventTrack, func(s *State) { s.Track = &PlayerInfo{Title: "Title", Artist: "Artist"} })
+	ui.handleKey("-")
+	if cmd := <-Commands; cmd.Offset != -tuiOffsetStep || ui.message != "Offset -100ms" {
+		t.Errorf("handleKey(-) = %q with %+v", ui.message, cmd)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wideRanges are the East Asian Wide and Fullwidth ranges and the emoji which
+// are shown with two cells
+var wideRanges = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115F, Stride: 1}, // Hangul Jamo initials
+		{Lo: 0x231A, Hi: 0x231B, Stride: 1},
+		{Lo: 0x2329, Hi: 0x232A, Stride: 1},
+		{Lo: 0x23E9, Hi: 0x23EC, Stride: 1},
+		{Lo: 0x23F0, Hi: 0x23F0, Stride: 1},
+		{Lo: 0x23F3, Hi: 0x23F3, Stride: 1},
+		{Lo: 0x25FD, Hi: 0x25FE, Stride: 1},
+		{Lo: 0x2614, Hi: 0x2615, Stride: 1},
+		{Lo: 0x2648, Hi: 0x2653, Stride: 1},
+		{Lo: 0x267F, Hi: 0x267F, Stride: 1},
+		{Lo: 0x2693, Hi: 0x2693, Stride: 1},
+		{Lo: 0x26A1, Hi: 0x26A1, Stride: 1},
+		{Lo: 0x26AA, Hi: 0x26AB, Stride: 1},
+		{Lo: 0x26BD, Hi: 0x26BE, Stride: 1},
+		{Lo: 0x26C4, Hi: 0x26C5, Stride: 1},
+		{Lo: 0x26CE, Hi: 0x26CE, Stride: 1},
+		{Lo: 0x26D4, Hi: 0x26D4, Stride: 1},
+		{Lo: 0x26EA, Hi: 0x26EA, Stride: 1},
+		{Lo: 0x26F2, Hi: 0x26F3, Stride: 1},
+		{Lo: 0x26F5, Hi: 0x26F5, Stride: 1},
+		{Lo: 0x26FA, Hi: 0x26FA, Stride: 1},
+		{Lo: 0x26FD, Hi: 0x26FD, Stride: 1},
+		{Lo: 0x2705, Hi: 0x2705, Stride: 1},
+		{Lo: 0x270A, Hi: 0x270B, Stride: 1},
+		{Lo: 0x2728, Hi: 0x2728, Stride: 1},
+		{Lo: 0x274C, Hi: 0x274C, Stride: 1},
+		{Lo: 0x274E, Hi: 0x274E, Stride: 1},
+		{Lo: 0x2753, Hi: 0x2755, Stride: 1},
+		{Lo: 0x2757, Hi: 0x2757, Stride: 1},
+		{Lo: 0x2795, Hi: 0x2797, Stride: 1},
+		{Lo: 0x27B0, Hi: 0x27B0, Stride: 1},
+		{Lo: 0x27BF, Hi: 0x27BF, Stride: 1},
+		{Lo: 0x2B1B, Hi: 0x2B1C, Stride: 1},
+		{Lo: 0x2B50, Hi: 0x2B50, Stride: 1},
+		{Lo: 0x2B55, Hi: 0x2B55, Stride: 1},
+		{Lo: 0x2E80, Hi: 0x303E, Stride: 1}, // CJK radicals, symbols and punctuation
+		{Lo: 0x3041, Hi: 0x33FF, Stride: 1}, // Kana, Bopomofo, Hangul compatibility jamo
+		{Lo: 0x3400, Hi: 0x4DBF, Stride: 1}, // CJK extension A
+		{Lo: 0x4E00, Hi: 0x9FFF, Stride: 1}, // CJK unified ideographs
+		{Lo: 0xA000, Hi: 0xA4CF, Stride: 1}, // Yi
+		{Lo: 0xA960, Hi: 0xA97F, Stride: 1},
+		{Lo: 0xAC00, Hi: 0xD7A3, Stride: 1}, // Hangul syllables
+		{Lo: 0xF900, Hi: 0xFAFF, Stride: 1}, // CJK compatibility ideographs
+		{Lo: 0xFE10, Hi: 0xFE19, Stride: 1},
+		{Lo: 0xFE30, Hi: 0xFE6F, Stride: 1},
+		{Lo: 0xFF00, Hi: 0xFF60, Stride: 1}, // Fullwidth forms
+		{Lo: 0xFFE0, Hi: 0xFFE6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x16FE0, Hi: 0x18AFF, Stride: 1}, // Tangut
+		{Lo: 0x1B000, Hi: 0x1B2FF, Stride: 1}, // Kana supplement
+		{Lo: 0x1F004, Hi: 0x1F004, Stride: 1},
+		{Lo: 0x1F0CF, Hi: 0x1F0CF, Stride: 1},
+		{Lo: 0x1F18E, Hi: 0x1F18E, Stride: 1},
+		{Lo: 0x1F191, Hi: 0x1F19A, Stride: 1},
+		{Lo: 0x1F200, Hi: 0x1F251, Stride: 1},
+		{Lo: 0x1F300, Hi: 0x1F320, Stride: 1}, // Emoji
+		{Lo: 0x1F32D, Hi: 0x1F335, Stride: 1},
+		{Lo: 0x1F337, Hi: 0x1F37C, Stride: 1},
+		{Lo: 0x1F37E, Hi: 0x1F393, Stride: 1},
+		{Lo: 0x1F3A0, Hi: 0x1F3CA, Stride: 1},
+		{Lo: 0x1F3CF, Hi: 0x1F3D3, Stride: 1},
+		{Lo: 0x1F3E0, Hi: 0x1F3F0, Stride: 1},
+		{Lo: 0x1F3F4, Hi: 0x1F3F4, Stride: 1},
+		{Lo: 0x1F3F8, Hi: 0x1F43E, Stride: 1},
+		{Lo: 0x1F440, Hi: 0x1F440, Stride: 1},
+		{Lo: 0x1F442, Hi: 0x1F4FC, Stride: 1},
+		{Lo: 0x1F4FF, Hi: 0x1F53D, Stride: 1},
+		{Lo: 0x1F54B, Hi: 0x1F54E, Stride: 1},
+		{Lo: 0x1F550, Hi: 0x1F567, Stride: 1},
+		{Lo: 0x1F57A, Hi: 0x1F57A, Stride: 1},
+		{Lo: 0x1F595, Hi: 0x1F596, Stride: 1},
+		{Lo: 0x1F5A4, Hi: 0x1F5A4, Stride: 1},
+		{Lo: 0x1F5FB, Hi: 0x1F64F, Stride: 1},
+		{Lo: 0x1F680, Hi: 0x1F6C5, Stride: 1},
+		{Lo: 0x1F6CC, Hi: 0x1F6CC, Stride: 1},
+		{Lo: 0x1F6D0, Hi: 0x1F6D2, Stride: 1},
+		{Lo: 0x1F6D5, Hi: 0x1F6D7, Stride: 1},
+		{Lo: 0x1F6DC, Hi: 0x1F6DF, Stride: 1},
+		{Lo: 0x1F6EB, Hi: 0x1F6EC, Stride: 1},
+		{Lo: 0x1F6F4, Hi: 0x1F6FC, Stride: 1},
+		{Lo: 0x1F7E0, Hi: 0x1F7EB, Stride: 1},
+		{Lo: 0x1F7F0, Hi: 0x1F7F0, Stride: 1},
+		{Lo: 0x1F90C, Hi: 0x1F93A, Stride: 1},
+		{Lo: 0x1F93C, Hi: 0x1F945, Stride: 1},
+		{Lo: 0x1F947, Hi: 0x1F9FF, Stride: 1},
+		{Lo: 0x1FA70, Hi: 0x1FAFF, Stride: 1},
+		{Lo: 0x20000, Hi: 0x2FFFD, Stride: 1}, // CJK extensions B to F
+		{Lo: 0x30000, Hi: 0x3FFFD, Stride: 1}, // CJK extensions G and H
+	},
+}
+
+const (
+	zeroWidthJoiner       = '\u200d'
+	emojiPresentation     = '\ufe0f'
+	regionalIndicatorLow  = 0x1F1E6
+	regionalIndicatorHigh = 0x1F1FF
+)
+
+// runeWidth returns the number of cells of a single rune
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r):
+		return 0
+	case unicode.Is(unicode.Variation_Selector, r):
+		return 0
+	case r >= 0x1160 && r <= 0x11FF: // Hangul Jamo vowels and finals
+		return 0
+	case unicode.Is(wideRanges, r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// extendsCluster reports whether r continues the grapheme cluster ending with
+// prev instead of starting a new one
+func extendsCluster(prev, r rune, indicators int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == zeroWidthJoiner:
+		return true
+	case r == zeroWidthJoiner:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Variation_Selector):
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // Emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // Tags of emoji flags
+		return true
+	case r >= 0x1160 && r <= 0x11FF: // Hangul Jamo vowels and finals
+		return true
+	case r >= regionalIndicatorLow && r <= regionalIndicatorHigh:
+		// Regional indicators pair up to flags
+		return indicators%2 == 1
+	}
+	return false
+}
+
+// Graphemes splits text into grapheme clusters: a base character with its
+// combining marks, variation selectors, emoji modifiers and ZWJ sequences.
+func Graphemes(text string) []string {
+	var clusters []string
+	start := 0
+	prev := rune(-1)
+	indicators := 0
+	for i, r := range text {
+		if prev >= 0 && !extendsCluster(prev, r, indicators) {
+			clusters = append(clusters, text[start:i])
+			start = i
+			indicators = 0
+		}
+		if r >= regionalIndicatorLow && r <= regionalIndicatorHigh {
+			indicators++
+		}
+		prev = r
+	}
+	if start < len(text) {
+		clusters = append(clusters, text[start:])
+	}
+	return clusters
+}
+
+// clusterWidth returns the number of cells of a grapheme cluster
+func clusterWidth(cluster string) int {
+	first, _ := utf8.DecodeRuneInString(cluster)
+	if first >= regionalIndicatorLow && first <= regionalIndicatorHigh && utf8.RuneCountInString(cluster) > 1 {
+		return 2
+	}
+	// Emoji presentation and emoji ZWJ sequences are shown as one emoji
+	if strings.ContainsRune(cluster, emojiPresentation) || (first >= 0x1F000 && strings.ContainsRune(cluster, zeroWidthJoiner)) {
+		return 2
+	}
+
+	width := 0
+	for _, r := range cluster {
+		if width = runeWidth(r); width != 0 {
+			break
+		}
+	}
+	return width
+}
+
+// StringWidth returns the number of cells text takes in the bar
+func StringWidth(text string) int {
+	width := 0
+	for _, cluster := range Graphemes(text) {
+		width += clusterWidth(cluster)
+	}
+	return width
+}
+
+// Truncate shortens text to width cells. Text is only cut between grapheme
+// clusters and ends with "..." when it is cut. With words, text is cut at the
+// last space which fits when there is one.
+func Truncate(text string, width int, words bool) string {
+	if StringWidth(text) <= width {
+		return text
+	}
+
+	const marker = "..."
+	limit := width
+	if width > len(marker) {
+		limit -= len(marker)
+	}
+
+	var cut strings.Builder
+	used := 0
+	lastSpace := -1
+	for _, cluster := range Graphemes(text) {
+		w := clusterWidth(cluster)
+		if used+w > limit {
+			break
+		}
+		if strings.TrimSpace(cluster) == "" {
+			lastSpace = cut.Len()
+		}
+		cut.WriteString(cluster)
+		used += w
+	}
+
+	result := cut.String()
+	if words && lastSpace > 0 {
+		result = result[:lastSpace]
+	}
+
+	if width > len(marker) {
+		return strings.TrimRightFunc(result, unicode.IsSpace) + marker
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "Latin", text: "abc", want: []string{"a", "b", "c"}},
+		{name: "Combining accent", text: "e\u0301te\u0301", want: []string{"e\u0301", "t", "e\u0301"}},
+		{name: "Variation selector", text: "❤\ufe0f!", want: []string{"❤\ufe0f", "!"}},
+		{name: "ZWJ sequence", text: "\U0001F468\u200d\U0001F469\u200d\U0001F467x", want: []string{"\U0001F468\u200d\U0001F469\u200d\U0001F467", "x"}},
+		{name: "Skin tone", text: "\U0001F44D\U0001F3FD", want: []string{"\U0001F44D\U0001F3FD"}},
+		{name: "Flags", text: "\U0001F1EF\U0001F1F5\U0001F1F0\U0001F1F7", want: []string{"\U0001F1EF\U0001F1F5", "\U0001F1F0\U0001F1F7"}},
+		{name: "Empty", text: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Graphemes(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Graphemes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "hello", want: 5},
+		{text: "君の名は", want: 8},
+		{text: "사랑해", want: 6},
+		{text: "ｆｕｌｌ", want: 8},
+		{text: "e\u0301", want: 1},
+		{text: "❤\ufe0f", want: 2},
+		{text: "\U0001F468\u200d\U0001F469\u200d\U0001F467", want: 2},
+		{text: "\U0001F1EF\U0001F1F5", want: 2},
+		{text: RightToLeftIsolate + "שלום" + PopDirectionalIsolate, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := StringWidth(tt.text); got != tt.want {
+				t.Errorf("StringWidth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		words bool
+		want  string
+	}{
+		{name: "Fits", text: "hello", width: 5, want: "hello"},
+		{name: "Latin", text: "hello world", width: 8, want: "hello..."},
+		{name: "Wide", text: "君の名は。君の名は", width: 10, want: "君の名..."},
+		{name: "Wide cut in the middle", text: "君の名は", width: 6, want: "君..."},
+		{name: "Combining accent", text: "cafe\u0301 au lait", width: 7, want: "cafe\u0301..."},
+		{name: "Emoji", text: "ab❤\ufe0fcdef", width: 6, want: "ab..."},
+		{name: "Word boundary", text: "never gonna give you up", width: 15, words: true, want: "never gonna..."},
+		{name: "Word longer than width", text: "supercalifragilistic", width: 10, words: true, want: "superca..."},
+		{name: "No room for marker", text: "hello", width: 3, want: "hel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.text, tt.width, tt.words); got != tt.want {
+				t.Errorf("Truncate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}